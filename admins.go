@@ -0,0 +1,62 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/NicoNex/echotron/v3"
+	"github.com/rs/zerolog/log"
+)
+
+// admins holds chats notified about problems: chats of users logged in with
+// the admin password together with chats from ADMIN_CHATS env variable. Being
+// notified does not grant admin rights, see adminUsers.
+var admins = struct {
+	sync.Mutex
+	chats map[int64]bool
+}{chats: make(map[int64]bool)}
+
+func loadAdmins() {
+	for _, v := range strings.Split(os.Getenv("ADMIN_CHATS"), ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			log.Error().Err(err).Str("chat", v).Msg("Failed to parse admin chat.")
+			continue
+		}
+		addAdmin(id)
+	}
+}
+
+func addAdmin(chatID int64) {
+	admins.Lock()
+	admins.chats[chatID] = true
+	admins.Unlock()
+}
+
+func adminChats() []int64 {
+	admins.Lock()
+	defer admins.Unlock()
+
+	chats := make([]int64, 0, len(admins.chats))
+	for id := range admins.chats {
+		chats = append(chats, id)
+	}
+	return chats
+}
+
+// notifyAdmins sends text in the language of every admin chat.
+func notifyAdmins(text func(lang string) string) {
+	api := echotron.NewAPI(os.Getenv("TOKEN"))
+	for _, id := range adminChats() {
+		// Admin chats are private, so chat ID is the ID of the admin.
+		if _, err := api.SendMessage(text(userLanguage(id, "")), id, nil); err != nil {
+			log.Error().Err(err).Int64("chat", id).Msg("Failed to notify admin.")
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// alertLimit is how many alerts are sent within alertWindow, further ones are
+// only logged until the window moves on.
+var alertLimit int
+var alertWindow time.Duration
+
+// alertWebhook receives alerts and their resolutions as JSON when set.
+var alertWebhook string
+
+var webhookClient = &http.Client{Timeout: 10 * time.Second}
+
+// alert is an ongoing problem. Raising it again while it is active does not
+// notify admins again, resolving it sends a follow-up.
+type alert struct {
+	key   string
+	msg   string
+	args  []interface{}
+	since time.Time
+	count int
+	// sent is set once admins were told, only then they hear it is resolved.
+	sent bool
+}
+
+// delivery is an alert on its way to admins.
+type delivery struct {
+	alert    alert
+	resolved bool
+}
+
+var alerts = struct {
+	sync.Mutex
+	active map[string]*alert
+	// sent holds send times of recent alerts for rate limiting.
+	sent  []time.Time
+	queue chan delivery
+}{active: make(map[string]*alert), queue: make(chan delivery, 64)}
+
+// loadAlerts reads alerting configuration and starts delivering alerts.
+func loadAlerts() {
+	alertLimit = envInt("ALERT_LIMIT", 10)
+	alertWindow = envDuration("ALERT_WINDOW", time.Hour)
+	alertWebhook = os.Getenv("ALERT_WEBHOOK")
+	go deliverAlerts()
+}
+
+// allowAlert reports whether an alert may be sent now, alerts must be locked.
+func allowAlert(now time.Time) bool {
+	recent := alerts.sent[:0]
+	for _, t := range alerts.sent {
+		if now.Sub(t) < alertWindow {
+			recent = append(recent, t)
+		}
+	}
+	alerts.sent = recent
+	if len(alerts.sent) >= alertLimit {
+		return false
+	}
+	alerts.sent = append(alerts.sent, now)
+	return true
+}
+
+// raiseAlert notifies admins about problem key with message msg, unless it
+// is already active.
+func raiseAlert(key, msg string, args ...interface{}) {
+	now := clk.Now()
+
+	alerts.Lock()
+	a, ok := alerts.active[key]
+	if !ok {
+		a = &alert{key: key, msg: msg, args: args, since: now}
+		alerts.active[key] = a
+	}
+	a.count++
+	// An alert held back by the rate limit goes out when it happens again.
+	send := !a.sent && allowAlert(now)
+	a.sent = a.sent || send
+	d := delivery{alert: *a}
+	alerts.Unlock()
+
+	if !send {
+		if !d.alert.sent {
+			log.Warn().Str("alert", key).Msg("Too many alerts, not notifying admins.")
+		}
+		return
+	}
+	log.Warn().Str("alert", key).Msg("Raised alert.")
+	queueAlert(d)
+}
+
+// resolveAlert ends problem key and tells admins it is over.
+func resolveAlert(key string) {
+	alerts.Lock()
+	a, ok := alerts.active[key]
+	delete(alerts.active, key)
+	alerts.Unlock()
+
+	if !ok {
+		return
+	}
+	log.Info().Str("alert", key).Int("count", a.count).Msg("Resolved alert.")
+	if a.sent {
+		queueAlert(delivery{alert: *a, resolved: true})
+	}
+}
+
+// queueAlert hands d to deliverAlerts without blocking the caller, which is
+// often a camera worker or the poll loop.
+func queueAlert(d delivery) {
+	select {
+	case alerts.queue <- d:
+	default:
+		log.Error().Str("alert", d.alert.key).Msg("Alert queue is full, dropping alert.")
+	}
+}
+
+// deliverAlerts sends alerts one by one, so a resolution never overtakes
+// its alert.
+func deliverAlerts() {
+	for d := range alerts.queue {
+		a := d.alert
+		text := func(lang string) string {
+			msg := tr(lang, a.msg, a.args...)
+			if d.resolved {
+				return tr(lang, "alert_resolved", msg, clk.Now().Sub(a.since).Round(time.Second), a.count)
+			}
+			return msg
+		}
+		notifyAdmins(text)
+		if alertWebhook != "" {
+			postAlert(a, d.resolved, text("en"))
+		}
+	}
+}
+
+// postAlert sends the alert to ALERT_WEBHOOK.
+func postAlert(a alert, resolved bool, text string) {
+	status := "firing"
+	if resolved {
+		status = "resolved"
+	}
+	body, err := json.Marshal(struct {
+		Key     string    `json:"key"`
+		Status  string    `json:"status"`
+		Message string    `json:"message"`
+		Since   time.Time `json:"since"`
+		Count   int       `json:"count"`
+	}{a.key, status, text, a.since, a.count})
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to marshal alert.")
+		return
+	}
+
+	resp, err := webhookClient.Post(alertWebhook, "application/json", bytes.NewReader(body))
+	if err != nil {
+		log.Error().Err(err).Str("alert", a.key).Msg("Failed to post alert to webhook.")
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		log.Error().Int("status", resp.StatusCode).Str("alert", a.key).Msg("Alert webhook rejected alert.")
+	}
+}
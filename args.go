@@ -0,0 +1,132 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// argKind is the type of a command argument.
+type argKind int
+
+const (
+	// argWord is any single word.
+	argWord argKind = iota
+	// argName is a word that is neither a number nor a condition, like a
+	// camera name.
+	argName
+	// argNumber is a whole number, + or - prefix is allowed.
+	argNumber
+	// argTime is a time of day as HH:MM.
+	argTime
+	// argCondition is a weather condition like clouds<80.
+	argCondition
+	// argRole is a role name.
+	argRole
+	// argJob is a job ID with optional # prefix.
+	argJob
+)
+
+// accepts reports whether s is an argument of kind k. Handlers still check
+// values, e.g. ranges of coordinates or whether the camera exists.
+func (k argKind) accepts(s string) bool {
+	switch k {
+	case argName:
+		return !argNumber.accepts(s) && !argCondition.accepts(s)
+	case argNumber:
+		_, err := strconv.Atoi(s)
+		return err == nil
+	case argTime:
+		h, m, ok := strings.Cut(s, ":")
+		return ok && argNumber.accepts(h) && argNumber.accepts(m)
+	case argCondition:
+		return strings.ContainsAny(s, "<>")
+	case argRole:
+		_, ok := parseRole(s)
+		return ok
+	case argJob:
+		return argNumber.accepts(strings.TrimPrefix(s, "#"))
+	}
+	return true
+}
+
+// param is a command argument, Name is shown in /help.
+type param struct {
+	Name string
+	Kind argKind
+}
+
+// argGroup is a run of arguments given all together, like X and Y of /photo.
+// Optional groups may be left out, a repeated one may be given any number of
+// times.
+type argGroup struct {
+	Params   []param
+	Optional bool
+	Repeated bool
+}
+
+func required(params ...param) argGroup {
+	return argGroup{Params: params}
+}
+
+func optional(params ...param) argGroup {
+	return argGroup{Params: params, Optional: true}
+}
+
+func repeated(params ...param) argGroup {
+	return argGroup{Params: params, Optional: true, Repeated: true}
+}
+
+// fits reports whether args start with arguments of g.
+func (g argGroup) fits(args []string) bool {
+	if len(args) < len(g.Params) {
+		return false
+	}
+	for i, p := range g.Params {
+		if !p.Kind.accepts(args[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (g argGroup) String() string {
+	names := make([]string, len(g.Params))
+	for i, p := range g.Params {
+		names[i] = p.Name
+	}
+	s := strings.Join(names, " ")
+	if g.Optional {
+		s = "[" + s + "]"
+	}
+	if g.Repeated {
+		s += "..."
+	}
+	return s
+}
+
+// matchArgs reports whether args are valid for the argument schema groups.
+func matchArgs(groups []argGroup, args []string) bool {
+	if len(groups) == 0 {
+		return len(args) == 0
+	}
+	g := groups[0]
+	if g.fits(args) {
+		next := groups[1:]
+		if g.Repeated {
+			next = groups
+		}
+		if matchArgs(next, args[len(g.Params):]) {
+			return true
+		}
+	}
+	return g.Optional && matchArgs(groups[1:], args)
+}
+
+// usage returns the command with its arguments, like "/photo [camera] [X Y]".
+func (cmd *command) usage() string {
+	parts := []string{"/" + cmd.Name}
+	for _, g := range cmd.Args {
+		parts = append(parts, g.String())
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+type fetchConfig struct {
+	URL      string
+	Timeout  time.Duration
+	Retries  int
+	Backoff  time.Duration
+	MaxBytes int64
+}
+
+var fetch fetchConfig
+
+var errPhotoTooBig = errors.New("photo is bigger than allowed size")
+
+// loadFetchConfig reads camera photo endpoint settings from env variables.
+func loadFetchConfig() {
+	fetch = fetchConfig{
+		URL:      os.Getenv("CAMERA_URL"),
+		Timeout:  envDuration("CAMERA_TIMEOUT", 15*time.Second),
+		Retries:  envInt("CAMERA_RETRIES", 3),
+		Backoff:  envDuration("CAMERA_BACKOFF", time.Second),
+		MaxBytes: int64(envInt("CAMERA_MAX_BYTES", 20<<20)),
+	}
+	if fetch.URL == "" {
+		fetch.URL = "http://127.0.0.1:8080/photoaf.jpg"
+	}
+	if fetch.Retries < 1 {
+		fetch.Retries = 1
+	}
+}
+
+// fetchPhoto downloads JPEG photo from the camera, retrying with exponential
+// backoff on network errors and bad responses.
+func fetchPhoto(ctx context.Context, url string) ([]byte, error) {
+	var err error
+	backoff := fetch.Backoff
+
+	for attempt := 1; attempt <= fetch.Retries; attempt++ {
+		var data []byte
+		data, err = fetchPhotoOnce(ctx, url)
+		if err == nil {
+			return data, nil
+		}
+		if errors.Is(err, errPhotoTooBig) {
+			return nil, err
+		}
+
+		log.Warn().Err(err).Int("attempt", attempt).Str("url", url).Msg("Failed to fetch photo.")
+		if attempt == fetch.Retries {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+	return nil, err
+}
+
+func fetchPhotoOnce(ctx context.Context, url string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, fetch.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %v", resp.Status)
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "image/jpeg") {
+		return nil, fmt.Errorf("unexpected content type %q", ct)
+	}
+	if resp.ContentLength > fetch.MaxBytes {
+		return nil, errPhotoTooBig
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, fetch.MaxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > fetch.MaxBytes {
+		return nil, errPhotoTooBig
+	}
+	if len(data) == 0 {
+		return nil, errors.New("empty photo")
+	}
+	return data, nil
+}
+
+type motorError struct {
+	err error
+}
+
+func (e *motorError) Error() string {
+	return "motor_driver: " + e.err.Error()
+}
+
+func (e *motorError) Unwrap() error {
+	return e.err
+}
+
+// camera is a single pan/tilt rig with its own motor driver and photo endpoint.
+type camera struct {
+	Name   string  `json:"name"`
+	Driver string  `json:"driver"`
+	Init   string  `json:"init"`
+	URL    string  `json:"url"`
+	Stream string  `json:"stream"`
+	Lat    float64 `json:"lat"`
+	Lng    float64 `json:"lng"`
+	North  int     `json:"north"`
+	Masks  []mask  `json:"masks"`
+
+	// mu guards the physical camera: moving the motor and fetching the photo
+	// must happen as one step, otherwise concurrent jobs get each other's photos.
+	mu sync.Mutex
+	// smu guards position and queue which are read while camera is busy.
+	smu     sync.Mutex
+	pos     position
+	jobs    []*job
+	wake    chan struct{}
+	breaker *breaker
+
+	lastCapture time.Time
+	lastErr     error
+	lastErrAt   time.Time
+}
+
+var cameras = make(map[string]*camera)
+var cameraNames []string
+
+// loadCameras reads cameras from JSON file at CAMERAS_FILE (cameras.json by
+// default). Without the file a single "main" camera is configured from env.
+func loadCameras() {
+	path := os.Getenv("CAMERAS_FILE")
+	if path == "" {
+		path = "cameras.json"
+	}
+
+	var list []*camera
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		list = []*camera{{Name: "main", North: envInt("CAMERA_NORTH", 0), Stream: os.Getenv("CAMERA_STREAM")}}
+	} else if err != nil {
+		log.Fatal().Err(err).Str("path", path).Msg("Failed to read cameras file.")
+	} else if err := json.Unmarshal(data, &list); err != nil {
+		log.Fatal().Err(err).Str("path", path).Msg("Failed to parse cameras file.")
+	}
+
+	for _, cam := range list {
+		cam.Name = strings.ToLower(cam.Name)
+		if cam.Name == "" || cameras[cam.Name] != nil {
+			log.Fatal().Str("camera", cam.Name).Msg("Camera name is empty or duplicated.")
+		}
+		if cam.Driver == "" {
+			cam.Driver = "./motor_driver.bin"
+		}
+		if cam.Init == "" {
+			cam.Init = "./phone_init.sh"
+		}
+		if cam.URL == "" {
+			cam.URL = fetch.URL
+		}
+		if cam.Stream == "" {
+			cam.Stream = streamURL(cam.URL)
+		}
+		if cam.Lat == 0 && cam.Lng == 0 {
+			cam.Lat, cam.Lng = cameraLat, cameraLng
+		}
+		cam.breaker = newBreaker(cam.Name)
+		cam.wake = make(chan struct{}, 1)
+		go cam.worker()
+
+		cameras[cam.Name] = cam
+		cameraNames = append(cameraNames, cam.Name)
+	}
+	if len(cameraNames) == 0 {
+		log.Fatal().Str("path", path).Msg("No cameras configured.")
+	}
+}
+
+func defaultCamera() *camera {
+	return cameras[cameraNames[0]]
+}
+
+// findCamera returns camera by name, empty name means the default camera.
+func findCamera(name string) (*camera, bool) {
+	if name == "" {
+		return defaultCamera(), true
+	}
+	cam, ok := cameras[strings.ToLower(name)]
+	return cam, ok
+}
+
+// bearing converts camera X coordinate to compass bearing in degrees.
+func (c *camera) bearing(x int) int {
+	return ((x+c.North)%360 + 360) % 360
+}
+
+func (c *camera) position() position {
+	c.smu.Lock()
+	defer c.smu.Unlock()
+	return c.pos
+}
+
+// setPosition remembers where the camera looks and persists it, so after
+// restart the bot knows the position without re-homing.
+func (c *camera) setPosition(pos position) {
+	c.smu.Lock()
+	c.pos = pos
+	c.smu.Unlock()
+
+	updateStore(func(p *persistent) {
+		p.Positions[c.Name] = pos
+	})
+}
+
+// restorePosition loads persisted position, it reports false if there is none.
+func (c *camera) restorePosition() bool {
+	var pos position
+	var ok bool
+	viewStore(func(p *persistent) {
+		pos, ok = p.Positions[c.Name]
+	})
+	if ok {
+		c.smu.Lock()
+		c.pos = pos
+		c.smu.Unlock()
+	}
+	return ok
+}
+
+// home moves camera to its initial position.
+func (c *camera) home() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := exec.Command(c.Driver, "0", "0", "True", "0", "3", "").Run(); err != nil {
+		return &motorError{err}
+	}
+	c.setPosition(position{})
+	return nil
+}
+
+// capture turns the camera to x, y and returns the photo bytes. Every call gets
+// its own buffer, nothing is written to the shared working directory.
+func (c *camera) capture(ctx context.Context, x, y int) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.move(ctx, x, y); err != nil {
+		return nil, err
+	}
+	return fetchPhoto(ctx, c.URL)
+}
+
+// park turns the camera to x, y without taking a photo. It gives up when ctx
+// is done before a capture still running releases the camera.
+func (c *camera) park(ctx context.Context, x, y int) error {
+	for !c.mu.TryLock() {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+	defer c.mu.Unlock()
+	return c.move(ctx, x, y)
+}
+
+// move runs the motor driver, c.mu must be held.
+func (c *camera) move(ctx context.Context, x, y int) error {
+	cmd := exec.CommandContext(ctx, c.Driver, fmt.Sprint(x), fmt.Sprint(y), "False", fmt.Sprint(c.position().X), "3", "")
+	if err := cmd.Run(); err != nil {
+		return &motorError{err}
+	}
+	c.setPosition(position{X: x, Y: y})
+	return nil
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+
+	"github.com/NicoNex/echotron/v3"
+	"github.com/rs/zerolog/log"
+)
+
+// clipMax is the longest clip in seconds a user may ask for.
+var clipMax int
+
+// ffmpeg is the command recording clips from camera streams.
+var ffmpeg string
+
+func loadClipConfig() {
+	clipMax = envInt("CLIP_MAX", 15)
+	ffmpeg = os.Getenv("FFMPEG")
+	if ffmpeg == "" {
+		ffmpeg = "ffmpeg"
+	}
+}
+
+// streamURL returns the video stream of the IP Webcam app serving photos at
+// photo URL.
+func streamURL(photo string) string {
+	u, err := url.Parse(photo)
+	if err != nil {
+		return ""
+	}
+	u.Path = "/video"
+	u.RawQuery = ""
+	return u.String()
+}
+
+// masked reports whether a privacy mask applies at x, y. Masks can not be
+// drawn over video, so clips are not recorded there.
+func (c *camera) masked(x, y int) bool {
+	for _, m := range c.Masks {
+		if m.applies(x, y) {
+			return true
+		}
+	}
+	return false
+}
+
+// record turns the camera to x, y and records secs seconds of its stream as
+// MP4. Every job gets its own temporary file.
+func (c *camera) record(ctx context.Context, x, y, secs int) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.move(ctx, x, y); err != nil {
+		return nil, err
+	}
+
+	f, err := os.CreateTemp("", "clip-*.mp4")
+	if err != nil {
+		return nil, err
+	}
+	name := f.Name()
+	f.Close()
+	defer os.Remove(name)
+
+	// Give ffmpeg time to connect to the stream on top of the clip length.
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(secs)*time.Second+fetch.Timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, ffmpeg, "-y", "-loglevel", "error", "-i", c.Stream, "-t", fmt.Sprint(secs),
+		"-an", "-c:v", "libx264", "-preset", "veryfast", "-pix_fmt", "yuv420p", "-movflags", "+faststart", name)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("ffmpeg: %w: %s", err, bytes.TrimSpace(out))
+	}
+	return os.ReadFile(name)
+}
+
+func (b *Bot) cmdClip(update *echotron.Update, args []string) stateFn {
+	if !b.needPrompt(args) {
+		return b.takeClip(update, args)
+	}
+	b.reply("clip_prompt", update.Message.From.FirstName, clipMax)
+	return b.await(b.handleClip)
+}
+
+func (b *Bot) handleClip(update *echotron.Update) stateFn {
+	if state, ok := b.checkCommands(update); ok {
+		return state
+	}
+
+	return b.takeClip(update, b.promptArgs(update))
+}
+
+// takeClip queues a clip from "[camera] X Y seconds" arguments, X and Y
+// prefixed with + or - are relative to the current camera position.
+func (b *Bot) takeClip(update *echotron.Update, args []string) stateFn {
+	cam, data, ok := b.cameraArg(update, args, 3)
+	if !ok {
+		return b.await(b.handleClip)
+	}
+	if len(data) != 3 {
+		log.Warn().Str("data", update.Message.Text).Msg("Coordinates and length are not three numbers.")
+		b.reply("clip_invalid", update.Message.From.FirstName)
+		return b.await(b.handleClip)
+	}
+	pos := cam.position()
+	x, err := parseCoord(data[0], pos.X)
+	y, err2 := parseCoord(data[1], pos.Y)
+	secs, err3 := strconv.Atoi(data[2])
+	if err != nil || err2 != nil || err3 != nil {
+		log.Warn().Strs("data", data).Msg("X, Y or length is not a number.")
+		b.reply("clip_invalid", update.Message.From.FirstName)
+		return b.await(b.handleClip)
+	}
+
+	if x < 0 || x > 360 {
+		log.Warn().Int("x", x).Msg("X is greater than 360 or negative.")
+		b.reply("x_range", update.Message.From.FirstName)
+		return b.await(b.handleClip)
+	} else if y < 0 || y > 90 {
+		log.Warn().Int("y", y).Msg("Y is greater than 90 or negative.")
+		b.reply("y_range", update.Message.From.FirstName)
+		return b.await(b.handleClip)
+	} else if secs < 1 || secs > clipMax {
+		log.Warn().Int("seconds", secs).Msg("Clip length is out of range.")
+		b.reply("clip_length", update.Message.From.FirstName, clipMax)
+		return b.await(b.handleClip)
+	} else if cam.forbidden(x, y) {
+		b.forbiddenZone(update, cam, x, y)
+		return b.await(b.handleClip)
+	} else if cam.masked(x, y) {
+		log.Warn().Str("camera", cam.Name).Ints("cords", []int{x, y}).Msg("Clip would show masked area.")
+		b.reply("clip_masked", update.Message.From.FirstName, cam.Name, x, y)
+		return b.await(b.handleClip)
+	} else if !cam.breaker.available() {
+		log.Warn().Str("camera", cam.Name).Msg("Camera breaker is open.")
+		b.reply("camera_unavailable")
+		return b.handleLogin
+	}
+
+	j, ok := cam.enqueueJob(&job{
+		Requester: userName(update.Message.From),
+		User:      update.Message.From.ID,
+		Chat:      b.chatID,
+		X:         x,
+		Y:         y,
+		Priority:  rolePriority(b.role()),
+		Clip:      secs,
+		bot:       b,
+	})
+	if !ok {
+		log.Warn().Str("camera", cam.Name).Int("task_count", cam.queueLen()).Msg("Queue is full.")
+		b.reply("queue_full")
+		return b.handleLogin
+	}
+	log.Info().Strs("user", []string{update.Message.From.FirstName, update.Message.From.LastName, update.Message.From.Username}).Str("camera", cam.Name).Ints("cords", []int{x, y}).Int("seconds", secs).Int("job", j.ID).Msg("Doing clip.")
+
+	b.replyQueued(j, "clip_queued", update.Message.From.FirstName, secs, j.ID)
+
+	return b.handleLogin
+}
+
+// sendClip records the clip of job j and sends it to the chat.
+func (b *Bot) sendClip(cam *camera, j *job, caption string) {
+	data, err := cam.recordWithRecovery(captures, j.X, j.Y, j.Clip)
+	if err != nil && captures.Err() != nil {
+		handBack(cam, j)
+		log.Warn().Str("camera", cam.Name).Ints("cords", []int{j.X, j.Y}).Int("job", j.ID).Msg("Shutting down, persisting cancelled clip.")
+		return
+	}
+	if err != nil {
+		cam.failed(err)
+		if classifyFailure(err) == failureMotor {
+			b.SendMessage(b.tr("motor_failed"), b.chatID, nil)
+			log.Error().Err(err).Msg("Failed to access motor_driver.")
+		} else {
+			b.SendMessage(b.tr("clip_failed"), b.chatID, nil)
+			log.Error().Err(err).Str("camera", cam.Name).Msg("Failed to record clip.")
+		}
+		return
+	}
+	name := fmt.Sprintf("clip_%v.mp4", clk.Now().Format("20060102_150405"))
+	opts := &echotron.VideoOptions{Caption: caption, Duration: j.Clip, SupportsStreaming: true}
+	if _, err := b.SendVideo(echotron.NewInputFileBytes(name, data), b.chatID, opts); err != nil {
+		cam.failed(err)
+		b.SendMessage(b.tr("send_failed"), b.chatID, nil)
+		log.Error().Err(err).Msg("Failed to send clip.")
+		return
+	}
+	cam.captured()
+}
@@ -0,0 +1,357 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/NicoNex/echotron/v3"
+	"github.com/rs/zerolog/log"
+)
+
+// command describes a bot command. Description is a message catalog key,
+// Args is the schema of accepted arguments, checked before Handler runs and
+// shown in /help.
+type command struct {
+	Name        string
+	Aliases     []string
+	Description string
+	Role        role
+	Args        []argGroup
+	// Capture commands move the camera, groups may restrict them.
+	Capture bool
+	Handler func(b *Bot, update *echotron.Update, args []string) stateFn
+}
+
+var commands []*command
+var commandIndex = make(map[string]*command)
+
+func registerCommands() {
+	cam := optional(param{"camera", argName})
+	x, y := param{"X", argNumber}, param{"Y", argNumber}
+
+	commands = []*command{
+		{Name: "help", Aliases: []string{"commands"}, Description: "cmd_help", Role: roleGuest, Handler: (*Bot).cmdHelp},
+		{Name: "photo", Aliases: []string{"p"}, Description: "cmd_photo", Role: roleGuest, Args: []argGroup{cam, optional(x, y)}, Capture: true, Handler: (*Bot).cmdPhoto},
+		{Name: "clip", Description: "cmd_clip", Role: roleGuest, Args: []argGroup{cam, optional(x, y, param{"seconds", argNumber})}, Capture: true, Handler: (*Bot).cmdClip},
+		{Name: "dice", Aliases: []string{"roll"}, Description: "cmd_dice", Role: roleGuest, Capture: true, Handler: (*Bot).cmdDice},
+		{Name: "camera", Description: "cmd_camera", Role: roleGuest, Args: []argGroup{cam}, Handler: (*Bot).cmdCamera},
+		{Name: "where", Description: "cmd_where", Role: roleGuest, Handler: (*Bot).cmdWhere},
+		{Name: "eventcreate", Description: "cmd_eventcreate", Role: roleAdmin, Args: []argGroup{cam, optional(x, y, param{"HH:MM", argTime})}, Handler: (*Bot).cmdEventCreate},
+		{Name: "eventdelete", Aliases: []string{"eventdel"}, Description: "cmd_eventdelete", Role: roleAdmin, Handler: (*Bot).cmdEventDelete},
+		{Name: "eventsunset", Description: "cmd_eventsunset", Role: roleAdmin, Args: []argGroup{cam, optional(x, y), repeated(param{"clouds<N|visibility>N", argCondition})}, Handler: (*Bot).cmdEventSunset},
+		{Name: "sunsettime", Aliases: []string{"sunset"}, Description: "cmd_sunsettime", Role: roleGuest, Handler: (*Bot).cmdSunsetTime},
+		{Name: "guestpass", Description: "cmd_guestpass", Role: roleAdmin, Handler: (*Bot).cmdGuestPass},
+		{Name: "zones", Description: "cmd_zones", Role: roleAdmin, Args: []argGroup{cam}, Handler: (*Bot).cmdZones},
+		{Name: "zoneadd", Description: "cmd_zoneadd", Role: roleAdmin, Args: []argGroup{cam, required(param{"X1", argNumber}, param{"Y1", argNumber}, param{"X2", argNumber}, param{"Y2", argNumber})}, Handler: (*Bot).cmdZoneAdd},
+		{Name: "zonedel", Description: "cmd_zonedel", Role: roleAdmin, Args: []argGroup{cam, required(param{"N", argNumber})}, Handler: (*Bot).cmdZoneDel},
+		{Name: "status", Description: "cmd_status", Role: roleAdmin, Handler: (*Bot).cmdStatus},
+		{Name: "role", Description: "cmd_role", Role: roleAdmin, Args: []argGroup{required(param{"guest|member|none", argRole})}, Handler: (*Bot).cmdRole},
+		{Name: "capture", Description: "cmd_capture", Role: roleAdmin, Args: []argGroup{optional(param{"guest|member|admin", argRole})}, Handler: (*Bot).cmdCapture},
+		{Name: "queue", Description: "cmd_queue", Role: roleGuest, Handler: (*Bot).cmdQueue},
+		{Name: "cancel", Description: "cmd_cancel", Role: roleGuest, Args: []argGroup{optional(param{"id", argJob})}, Handler: (*Bot).cmdCancel},
+		{Name: "language", Description: "cmd_language", Role: roleGuest, Args: []argGroup{optional(param{"code", argWord})}, Handler: (*Bot).cmdLanguage},
+	}
+
+	for _, cmd := range commands {
+		for _, name := range append([]string{cmd.Name}, cmd.Aliases...) {
+			if commandIndex[name] != nil {
+				log.Fatal().Str("command", name).Msg("Command is registered twice.")
+			}
+			commandIndex[name] = cmd
+		}
+	}
+}
+
+// parseCommand splits "/name@bot arg1 arg2" into command name and arguments.
+// Commands addressed to another bot are ignored.
+func parseCommand(text string) (string, []string, bool) {
+	fields := strings.Fields(text)
+	if len(fields) == 0 || !strings.HasPrefix(fields[0], "/") {
+		return "", nil, false
+	}
+	name, bot, addressed := strings.Cut(strings.TrimPrefix(fields[0], "/"), "@")
+	if addressed && botUsername != "" && !strings.EqualFold(bot, botUsername) {
+		return "", nil, false
+	}
+	return strings.ToLower(name), fields[1:], true
+}
+
+func (b *Bot) checkCommands(update *echotron.Update) (stateFn, bool) {
+	name, args, ok := parseCommand(update.Message.Text)
+	if !ok {
+		return nil, false
+	}
+	cmd, ok := commandIndex[name]
+	if !ok {
+		return nil, false
+	}
+
+	r := b.role()
+	if r == roleNone {
+		log.Warn().Strs("user", []string{update.Message.From.FirstName, update.Message.From.LastName, update.Message.From.Username}).Str("command", cmd.Name).Msg("User is not logged in.")
+		b.reply("group_login", update.Message.From.FirstName, botUsername)
+		return b.handleLogin, true
+	} else if r < cmd.Role {
+		log.Warn().Strs("user", []string{update.Message.From.FirstName, update.Message.From.LastName, update.Message.From.Username}).Str("command", cmd.Name).Msg("Guest can not do that.")
+		b.reply("guest_denied", update.Message.From.FirstName)
+		return b.handleLogin, true
+	} else if cmd.Capture && stopping.Load() {
+		log.Warn().Str("command", cmd.Name).Msg("Shutting down, not accepting photos.")
+		b.reply("shutting_down")
+		return b.handleLogin, true
+	} else if cmd.Capture && !b.mayCapture() {
+		log.Warn().Strs("user", []string{update.Message.From.FirstName, update.Message.From.LastName, update.Message.From.Username}).Str("command", cmd.Name).Msg("Group does not allow user to capture.")
+		b.reply("capture_denied", update.Message.From.FirstName)
+		return b.handleLogin, true
+	} else if !matchArgs(cmd.Args, args) {
+		log.Warn().Str("command", cmd.Name).Strs("args", args).Msg("Invalid command arguments.")
+		b.reply("command_usage", update.Message.From.FirstName, cmd.usage())
+		return b.handleLogin, true
+	}
+	return cmd.Handler(b, update, args), true
+}
+
+// helpText lists commands available for role r in language lang.
+func helpText(lang string, r role) string {
+	var lines []string
+	for _, cmd := range commands {
+		if r < cmd.Role {
+			continue
+		}
+		lines = append(lines, cmd.usage()+" - "+tr(lang, cmd.Description))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// botCommands returns the command menu for role r in language lang.
+func botCommands(lang string, r role) []echotron.BotCommand {
+	var menu []echotron.BotCommand
+	for _, cmd := range commands {
+		if r >= cmd.Role {
+			menu = append(menu, echotron.BotCommand{Command: cmd.Name, Description: tr(lang, cmd.Description)})
+		}
+	}
+	return menu
+}
+
+// setCommandMenu publishes guest commands as the default menu for every
+// language. Admin chats get their own menu in setAdminMenu.
+func setCommandMenu() {
+	api := echotron.NewAPI(os.Getenv("TOKEN"))
+	for _, lang := range languageCodes() {
+		opts := &echotron.CommandOptions{Scope: echotron.BotCommandScope{Type: echotron.BCSTDefault}}
+		if lang != defaultLanguage {
+			opts.LanguageCode = lang
+		}
+		if _, err := api.SetMyCommands(opts, botCommands(lang, roleGuest)...); err != nil {
+			log.Error().Err(err).Str("language", lang).Msg("Failed to set command menu.")
+		}
+	}
+
+	for _, id := range adminChats() {
+		setAdminMenu(id)
+	}
+}
+
+// setAdminMenu shows admin commands in the menu of a single chat.
+func setAdminMenu(chatID int64) {
+	api := echotron.NewAPI(os.Getenv("TOKEN"))
+	for _, lang := range languageCodes() {
+		opts := &echotron.CommandOptions{Scope: echotron.BotCommandScope{Type: echotron.BCSTChat, ChatID: chatID}}
+		if lang != defaultLanguage {
+			opts.LanguageCode = lang
+		}
+		if _, err := api.SetMyCommands(opts, botCommands(lang, roleAdmin)...); err != nil {
+			log.Error().Err(err).Int64("chat", chatID).Str("language", lang).Msg("Failed to set admin command menu.")
+		}
+	}
+}
+
+func (b *Bot) cmdHelp(update *echotron.Update, args []string) stateFn {
+	if _, err := b.SendMessage(helpText(b.language(), b.role()), b.chatID, nil); err != nil {
+		log.Error().Err(err).Msg("Failed to send message.")
+		time.Sleep(10 * time.Second)
+	}
+	return b.handleLogin
+}
+
+func (b *Bot) cmdPhoto(update *echotron.Update, args []string) stateFn {
+	if !b.needPrompt(args) {
+		return b.takePhoto(update, args)
+	}
+	b.reply("photo_prompt", update.Message.From.FirstName)
+	return b.await(b.handlePhoto)
+}
+
+func (b *Bot) cmdCamera(update *echotron.Update, args []string) stateFn {
+	if len(args) == 0 {
+		cam := b.defaultCamera()
+		b.reply("camera_current", update.Message.From.FirstName, cam.Name, strings.Join(cameraNames, ", "))
+		return b.handleLogin
+	}
+
+	cam, ok := findCamera(args[0])
+	if !ok {
+		b.unknownCamera(update, args[0])
+		return b.handleLogin
+	}
+	updateStore(func(p *persistent) {
+		p.Cameras[b.chatID] = cam.Name
+	})
+	log.Info().Strs("user", []string{update.Message.From.FirstName, update.Message.From.LastName, update.Message.From.Username}).Str("camera", cam.Name).Msg("Changed default camera.")
+	b.reply("camera_set", update.Message.From.FirstName, cam.Name)
+	return b.handleLogin
+}
+
+func (b *Bot) cmdLanguage(update *echotron.Update, args []string) stateFn {
+	if len(args) == 0 {
+		b.reply("language_current", update.Message.From.FirstName, languageNames[b.language()], strings.Join(languageCodes(), ", "))
+		return b.handleLogin
+	}
+
+	code := strings.ToLower(args[0])
+	if _, ok := messages[code]; !ok {
+		log.Warn().Str("language", code).Msg("Unknown language.")
+		b.reply("language_unknown", update.Message.From.FirstName, code, strings.Join(languageCodes(), ", "))
+		return b.handleLogin
+	}
+	updateStore(func(p *persistent) {
+		p.Languages[update.Message.From.ID] = code
+	})
+	b.setLanguage(code)
+	log.Info().Strs("user", []string{update.Message.From.FirstName, update.Message.From.LastName, update.Message.From.Username}).Str("language", code).Msg("Changed language.")
+	b.reply("language_set", update.Message.From.FirstName)
+	return b.handleLogin
+}
+
+func (b *Bot) cmdWhere(update *echotron.Update, args []string) stateFn {
+	var lines []string
+	for _, name := range cameraNames {
+		pos := cameras[name].position()
+		lines = append(lines, fmt.Sprintf("%v: X: %v Y: %v", name, pos.X, pos.Y))
+	}
+	b.reply("where", update.Message.From.FirstName, strings.Join(lines, "\n"))
+	return b.handleLogin
+}
+
+func (b *Bot) cmdDice(update *echotron.Update, args []string) stateFn {
+	cam := b.defaultCamera()
+	if !cam.breaker.available() {
+		log.Warn().Str("camera", cam.Name).Msg("Camera breaker is open.")
+		b.reply("camera_unavailable")
+		return b.handleLogin
+	}
+	if cam.full(rolePriority(b.role())) {
+		log.Warn().Str("camera", cam.Name).Int("task_count", cam.queueLen()).Msg("Queue is full.")
+		b.reply("queue_full")
+		return b.handleLogin
+	}
+
+	// Re-roll while dice land in a private zone.
+	var x, y int
+	landed := false
+	for roll := 0; roll < diceRolls && !landed; roll++ {
+		data, err := b.SendDice(b.chatID, "🎲", nil)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to send dice.")
+			time.Sleep(10 * time.Second)
+			return b.handleLogin
+		}
+		data2, err := b.SendDice(b.chatID, "🎲", nil)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to send dice.")
+			time.Sleep(10 * time.Second)
+			return b.handleLogin
+		}
+
+		x = 360 / 6 * data.Result.Dice.Value
+		y = 90 / 6 * data2.Result.Dice.Value
+
+		time.Sleep(diceDelay)
+
+		landed = !cam.forbidden(x, y)
+		if !landed {
+			log.Info().Str("camera", cam.Name).Ints("cords", []int{x, y}).Msg("Dice landed in no-go zone, rolling again.")
+			if _, err := b.SendMessage(b.tr("dice_reroll", x, y), b.chatID, nil); err != nil {
+				log.Error().Err(err).Msg("Failed to send message.")
+			}
+		}
+	}
+	if !landed {
+		log.Warn().Str("camera", cam.Name).Msg("Dice kept landing in no-go zones.")
+		b.reply("dice_failed", update.Message.From.FirstName)
+		return b.handleLogin
+	}
+
+	j, ok := cam.enqueue(b, update.Message.From.ID, userName(update.Message.From), x, y, rolePriority(b.role()))
+	if !ok {
+		log.Warn().Str("camera", cam.Name).Int("task_count", cam.queueLen()).Msg("Queue is full.")
+		b.reply("queue_full")
+		return b.handleLogin
+	}
+	log.Info().Strs("user", []string{update.Message.From.FirstName, update.Message.From.LastName, update.Message.From.Username}).Str("camera", cam.Name).Ints("cords", []int{x, y}).Int("job", j.ID).Msg("Doing dice photo.")
+
+	b.replyQueued(j, "dice_photo", update.Message.From.FirstName, x, y, j.ID)
+
+	return b.handleLogin
+}
+
+func (b *Bot) cmdEventCreate(update *echotron.Update, args []string) stateFn {
+	if ev, ok := chatEvent(b.chatID); ok {
+		b.reply("event_exists", update.Message.From.FirstName, ev.X, ev.Y, ev.Hour, ev.Minute, ev.Sunset)
+		log.Warn().Strs("user", []string{update.Message.From.FirstName, update.Message.From.LastName, update.Message.From.Username}).Msg("User have event already.")
+		return b.handleLogin
+	}
+
+	if !b.needPrompt(args) {
+		return b.createEvent(update, args)
+	}
+	b.reply("event_prompt", update.Message.From.FirstName)
+	return b.await(b.handleEventCreate)
+}
+
+func (b *Bot) cmdEventDelete(update *echotron.Update, args []string) stateFn {
+	ev, ok := removeEvent(b.chatID)
+	if !ok {
+		b.reply("event_none", update.Message.From.FirstName)
+		log.Warn().Strs("user", []string{update.Message.From.FirstName, update.Message.From.LastName, update.Message.From.Username}).Msg("User have no events.")
+		return b.handleLogin
+	}
+
+	b.reply("event_deleted", update.Message.From.FirstName, ev.X, ev.Y, ev.Hour, ev.Minute, ev.Sunset)
+	log.Info().Strs("user", []string{update.Message.From.FirstName, update.Message.From.LastName, update.Message.From.Username}).Ints("cords", []int{ev.X, ev.Y}).Ints("time", []int{ev.Hour, ev.Minute}).Bool("sunset", ev.Sunset).Msg("Deleted event.")
+
+	return b.handleLogin
+}
+
+func (b *Bot) cmdEventSunset(update *echotron.Update, args []string) stateFn {
+	if _, ok := chatEvent(b.chatID); ok {
+		log.Warn().Strs("user", []string{update.Message.From.FirstName, update.Message.From.LastName, update.Message.From.Username}).Msg("User already have an event.")
+		b.reply("sunset_exists", update.Message.From.FirstName)
+		return b.handleLogin
+	}
+	conds, rest := splitConditions(args)
+	if !b.needPrompt(rest) {
+		return b.createSunset(update, args)
+	}
+	if len(conds) > 0 {
+		// Keep conditions for the answer together with the camera.
+		b.user.partial = args
+	}
+	b.reply("sunset_prompt")
+	return b.await(b.handleSunset)
+}
+
+func (b *Bot) cmdSunsetTime(update *echotron.Update, args []string) stateFn {
+	hour, minute := sunsetTime()
+	b.reply("sunset_time", update.Message.From.FirstName, hour, minute)
+	return b.handleLogin
+}
+
+func (b *Bot) cmdGuestPass(update *echotron.Update, args []string) stateFn {
+	pass, _ := currentGuestPass()
+	b.reply("guestpass", update.Message.From.FirstName, pass)
+	return b.handleLogin
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+func envInt(key string, def int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		log.Error().Err(err).Str("key", key).Str("value", v).Msg("Failed to parse env variable, using default.")
+		return def
+	}
+	return n
+}
+
+func envFloat(key string, def float64) float64 {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		log.Error().Err(err).Str("key", key).Str("value", v).Msg("Failed to parse env variable, using default.")
+		return def
+	}
+	return n
+}
+
+func envBool(key string, def bool) bool {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		log.Error().Err(err).Str("key", key).Str("value", v).Msg("Failed to parse env variable, using default.")
+		return def
+	}
+	return b
+}
+
+func envDuration(key string, def time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Error().Err(err).Str("key", key).Str("value", v).Msg("Failed to parse env variable, using default.")
+		return def
+	}
+	return d
+}
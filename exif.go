@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"time"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+type exifMeta struct {
+	Time      time.Time
+	Lat       float64
+	Lng       float64
+	Direction int
+	Comment   string
+}
+
+const (
+	tiffByte      = 1
+	tiffASCII     = 2
+	tiffLong      = 4
+	tiffRational  = 5
+	tiffUndefined = 7
+)
+
+type ifdEntry struct {
+	tag   uint16
+	typ   uint16
+	count uint32
+	data  []byte
+}
+
+var errNotJPEG = errors.New("data is not a JPEG image")
+
+// embedExif replaces EXIF segment of JPEG image with the one built from meta.
+func embedExif(data []byte, meta exifMeta) ([]byte, error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, errNotJPEG
+	}
+
+	var out bytes.Buffer
+	out.Write(data[:2])
+
+	rest := data[2:]
+	// Keep JFIF header in front, it must be the first segment.
+	if len(rest) >= 4 && rest[0] == 0xFF && rest[1] == 0xE0 {
+		n := int(binary.BigEndian.Uint16(rest[2:4])) + 2
+		if n > len(rest) {
+			return nil, errNotJPEG
+		}
+		out.Write(rest[:n])
+		rest = rest[n:]
+	}
+
+	tiff := buildTIFF(meta)
+	out.Write([]byte{0xFF, 0xE1})
+	binary.Write(&out, binary.BigEndian, uint16(2+6+len(tiff)))
+	out.WriteString("Exif\x00\x00")
+	out.Write(tiff)
+
+	// Drop existing EXIF segments, they would shadow ours.
+	for len(rest) >= 4 && rest[0] == 0xFF && rest[1] >= 0xE0 && rest[1] <= 0xEF {
+		n := int(binary.BigEndian.Uint16(rest[2:4])) + 2
+		if n > len(rest) {
+			return nil, errNotJPEG
+		}
+		if rest[1] != 0xE1 || !bytes.HasPrefix(rest[4:n], []byte("Exif\x00")) {
+			out.Write(rest[:n])
+		}
+		rest = rest[n:]
+	}
+	out.Write(rest)
+
+	return out.Bytes(), nil
+}
+
+func buildTIFF(meta exifMeta) []byte {
+	stamp := asciiValue(meta.Time.Format("2006:01:02 15:04:05"))
+
+	ifd0 := []ifdEntry{
+		{tag: 0x0132, typ: tiffASCII, count: uint32(len(stamp)), data: stamp},
+		{tag: 0x8769, typ: tiffLong, count: 1},
+		{tag: 0x8825, typ: tiffLong, count: 1},
+	}
+
+	comment := commentValue(meta.Comment)
+	exififd := []ifdEntry{
+		{tag: 0x9000, typ: tiffUndefined, count: 4, data: []byte("0232")},
+		{tag: 0x9003, typ: tiffASCII, count: uint32(len(stamp)), data: stamp},
+		{tag: 0x9286, typ: tiffUndefined, count: uint32(len(comment)), data: comment},
+	}
+
+	latref, lngref := "N", "E"
+	if meta.Lat < 0 {
+		latref = "S"
+	}
+	if meta.Lng < 0 {
+		lngref = "W"
+	}
+	gpsifd := []ifdEntry{
+		{tag: 0x0000, typ: tiffByte, count: 4, data: []byte{2, 3, 0, 0}},
+		{tag: 0x0001, typ: tiffASCII, count: 2, data: asciiValue(latref)},
+		{tag: 0x0002, typ: tiffRational, count: 3, data: degreesValue(meta.Lat)},
+		{tag: 0x0003, typ: tiffASCII, count: 2, data: asciiValue(lngref)},
+		{tag: 0x0004, typ: tiffRational, count: 3, data: degreesValue(meta.Lng)},
+		{tag: 0x0010, typ: tiffASCII, count: 2, data: asciiValue("T")},
+		{tag: 0x0011, typ: tiffRational, count: 1, data: rationalValue(uint32(meta.Direction), 1)},
+	}
+
+	exifoff := 8 + ifdSize(ifd0)
+	gpsoff := exifoff + ifdSize(exififd)
+	ifd0[1].data = binary.BigEndian.AppendUint32(nil, uint32(exifoff))
+	ifd0[2].data = binary.BigEndian.AppendUint32(nil, uint32(gpsoff))
+
+	var buf bytes.Buffer
+	buf.WriteString("MM")
+	binary.Write(&buf, binary.BigEndian, uint16(42))
+	binary.Write(&buf, binary.BigEndian, uint32(8))
+	writeIFD(&buf, ifd0, 8)
+	writeIFD(&buf, exififd, exifoff)
+	writeIFD(&buf, gpsifd, gpsoff)
+	return buf.Bytes()
+}
+
+// ifdSize returns the size of IFD with its out of line values.
+func ifdSize(entries []ifdEntry) int {
+	size := 2 + 12*len(entries) + 4
+	for _, e := range entries {
+		if len(e.data) > 4 {
+			size += len(e.data) + len(e.data)%2
+		}
+	}
+	return size
+}
+
+// writeIFD writes IFD located at offset off from the TIFF header start.
+func writeIFD(buf *bytes.Buffer, entries []ifdEntry, off int) {
+	var values bytes.Buffer
+	valoff := off + 2 + 12*len(entries) + 4
+
+	binary.Write(buf, binary.BigEndian, uint16(len(entries)))
+	for _, e := range entries {
+		binary.Write(buf, binary.BigEndian, e.tag)
+		binary.Write(buf, binary.BigEndian, e.typ)
+		binary.Write(buf, binary.BigEndian, e.count)
+		if len(e.data) <= 4 {
+			var inline [4]byte
+			copy(inline[:], e.data)
+			buf.Write(inline[:])
+			continue
+		}
+		binary.Write(buf, binary.BigEndian, uint32(valoff+values.Len()))
+		values.Write(e.data)
+		if len(e.data)%2 == 1 {
+			values.WriteByte(0)
+		}
+	}
+	binary.Write(buf, binary.BigEndian, uint32(0))
+	buf.Write(values.Bytes())
+}
+
+func asciiValue(s string) []byte {
+	return append([]byte(s), 0)
+}
+
+// commentValue encodes UserComment, names outside ASCII (Latvian, Cyrillic)
+// are written as UCS-2 in the byte order of the TIFF.
+func commentValue(s string) []byte {
+	ascii := true
+	for _, r := range s {
+		ascii = ascii && r < utf8.RuneSelf
+	}
+	if ascii {
+		return append([]byte("ASCII\x00\x00\x00"), s...)
+	}
+	data := []byte("UNICODE\x00")
+	for _, u := range utf16.Encode([]rune(s)) {
+		data = binary.BigEndian.AppendUint16(data, u)
+	}
+	return data
+}
+
+func rationalValue(num, den uint32) []byte {
+	return binary.BigEndian.AppendUint32(binary.BigEndian.AppendUint32(nil, num), den)
+}
+
+// degreesValue encodes coordinate as degrees, minutes and seconds rationals.
+func degreesValue(coord float64) []byte {
+	coord = math.Abs(coord)
+	deg := math.Floor(coord)
+	mins := math.Floor((coord - deg) * 60)
+	sec := ((coord-deg)*60 - mins) * 60
+
+	data := rationalValue(uint32(deg), 1)
+	data = append(data, rationalValue(uint32(mins), 1)...)
+	return append(data, rationalValue(uint32(math.Round(sec*1000)), 1000)...)
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/NicoNex/echotron/v3"
+)
+
+// sent is a message the bot sent to a chat through the fake API.
+type sent struct {
+	Method      string
+	Chat        int64
+	Text        string
+	Caption     string
+	Emoji       string
+	Dice        int
+	ReplyMarkup string
+	// Files holds uploaded photos, documents or videos, several for media
+	// groups.
+	Files [][]byte
+}
+
+// fakeAPI is a Telegram Bot API server good enough for the bot. Requests to
+// api.telegram.org are redirected to it by replacing http.DefaultTransport.
+type fakeAPI struct {
+	srv *httptest.Server
+
+	mu       sync.Mutex
+	changed  chan struct{}
+	updates  []*echotron.Update
+	updateID int
+	msgID    int
+	sent     map[int64][]sent
+	read     map[int64]int
+	// dice are values returned by sendDice, 1 when empty.
+	dice []int
+	// calls counts requests per method.
+	calls map[string]int
+}
+
+func newFakeAPI() *fakeAPI {
+	f := &fakeAPI{
+		changed: make(chan struct{}),
+		sent:    make(map[int64][]sent),
+		read:    make(map[int64]int),
+		calls:   make(map[string]int),
+	}
+	f.srv = httptest.NewServer(http.HandlerFunc(f.serve))
+	return f
+}
+
+// redirect sends Bot API requests to the fake server and everything else to
+// the real transport.
+type redirect struct {
+	target *url.URL
+	next   http.RoundTripper
+}
+
+func (r redirect) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Host != "api.telegram.org" {
+		return r.next.RoundTrip(req)
+	}
+	req = req.Clone(req.Context())
+	req.URL.Scheme = r.target.Scheme
+	req.URL.Host = r.target.Host
+	req.Host = r.target.Host
+	return r.next.RoundTrip(req)
+}
+
+// install points echotron to the fake server.
+func (f *fakeAPI) install() {
+	target, _ := url.Parse(f.srv.URL)
+	http.DefaultTransport = redirect{target: target, next: http.DefaultTransport}
+}
+
+// notify wakes everybody waiting for updates or sent messages, f.mu must be held.
+func (f *fakeAPI) notify() {
+	close(f.changed)
+	f.changed = make(chan struct{})
+}
+
+// message queues a text message from user in chat.
+func (f *fakeAPI) message(chat int64, user *echotron.User, text string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.updateID++
+	f.msgID++
+	chatType := "private"
+	if chat < 0 {
+		chatType = "group"
+	}
+	f.updates = append(f.updates, &echotron.Update{
+		ID: f.updateID,
+		Message: &echotron.Message{
+			ID:   f.msgID,
+			From: user,
+			Chat: echotron.Chat{ID: chat, Type: chatType},
+			Date: int(time.Now().Unix()),
+			Text: text,
+		},
+	})
+	f.notify()
+}
+
+// wait returns the next n messages sent to chat or fails after timeout.
+func (f *fakeAPI) wait(t *testing.T, chat int64, n int, timeout time.Duration) []sent {
+	t.Helper()
+	deadline := time.After(timeout)
+	for {
+		f.mu.Lock()
+		all := f.sent[chat]
+		if len(all)-f.read[chat] >= n {
+			got := append([]sent(nil), all[f.read[chat]:f.read[chat]+n]...)
+			f.read[chat] += n
+			f.mu.Unlock()
+			return got
+		}
+		changed := f.changed
+		f.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-deadline:
+			f.mu.Lock()
+			got := all[f.read[chat]:]
+			f.mu.Unlock()
+			t.Fatalf("chat %v: waited for %v messages, got %v: %+v", chat, n, len(got), got)
+		}
+	}
+}
+
+// waitCalls blocks until method was called n times.
+func (f *fakeAPI) waitCalls(method string, n int) {
+	for {
+		f.mu.Lock()
+		calls := f.calls[method]
+		f.mu.Unlock()
+		if calls >= n {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// quiet fails if chat receives anything within d.
+func (f *fakeAPI) quiet(t *testing.T, chat int64, d time.Duration) {
+	t.Helper()
+	time.Sleep(d)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if extra := f.sent[chat][f.read[chat]:]; len(extra) > 0 {
+		t.Fatalf("chat %v: unexpected messages %+v", chat, extra)
+	}
+}
+
+func (f *fakeAPI) serve(w http.ResponseWriter, r *http.Request) {
+	// Path is /bot<token>/<method>.
+	method := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+		r.ParseMultipartForm(64 << 20)
+	} else {
+		r.ParseForm()
+	}
+
+	f.mu.Lock()
+	f.calls[method]++
+	f.mu.Unlock()
+
+	switch method {
+	case "getUpdates":
+		f.getUpdates(w, r)
+	case "sendMessage", "sendPhoto", "sendDocument", "sendVideo", "sendDice":
+		f.reply(w, f.record(method, r))
+	case "sendMediaGroup":
+		f.sendMediaGroup(w, r)
+	case "getMe":
+		f.reply(w, echotron.User{ID: 1, IsBot: true, FirstName: "Camera", Username: "CameraBot"})
+	default:
+		// deleteWebhook, setMyCommands, answerCallbackQuery and the like.
+		f.reply(w, true)
+	}
+}
+
+func (f *fakeAPI) reply(w http.ResponseWriter, result interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"ok": true, "result": result})
+}
+
+func (f *fakeAPI) getUpdates(w http.ResponseWriter, r *http.Request) {
+	offset, _ := strconv.Atoi(r.FormValue("offset"))
+	timeout, _ := strconv.Atoi(r.FormValue("timeout"))
+	// Keep long polling short, so tests do not wait on shutdown.
+	deadline := time.After(time.Duration(timeout) * time.Second / 60)
+
+	for {
+		f.mu.Lock()
+		var res []*echotron.Update
+		for _, u := range f.updates {
+			if u.ID >= offset {
+				res = append(res, u)
+			}
+		}
+		changed := f.changed
+		f.mu.Unlock()
+
+		if len(res) > 0 || timeout == 0 {
+			f.reply(w, res)
+			return
+		}
+		select {
+		case <-changed:
+		case <-deadline:
+			f.reply(w, res)
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func readFile(r *http.Request, name string) []byte {
+	if r.MultipartForm == nil || len(r.MultipartForm.File[name]) == 0 {
+		return nil
+	}
+	file, err := r.MultipartForm.File[name][0].Open()
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+	data, _ := io.ReadAll(file)
+	return data
+}
+
+// record stores a message sent by the bot and returns it as Telegram would.
+func (f *fakeAPI) record(method string, r *http.Request) *echotron.Message {
+	chat, _ := strconv.ParseInt(r.FormValue("chat_id"), 10, 64)
+	s := sent{
+		Method:      method,
+		Chat:        chat,
+		Text:        r.FormValue("text"),
+		Caption:     r.FormValue("caption"),
+		Emoji:       r.FormValue("emoji"),
+		ReplyMarkup: r.FormValue("reply_markup"),
+	}
+	for _, name := range []string{"photo", "document", "video"} {
+		if data := readFile(r, name); data != nil {
+			s.Files = append(s.Files, data)
+		}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if method == "sendDice" {
+		s.Dice = 1
+		if len(f.dice) > 0 {
+			s.Dice, f.dice = f.dice[0], f.dice[1:]
+		}
+	}
+	f.msgID++
+	f.sent[chat] = append(f.sent[chat], s)
+	f.notify()
+
+	msg := &echotron.Message{ID: f.msgID, Chat: echotron.Chat{ID: chat}, Date: int(time.Now().Unix()), Text: s.Text, Caption: s.Caption}
+	if method == "sendDice" {
+		msg.Dice = &echotron.Dice{Emoji: s.Emoji, Value: s.Dice}
+	}
+	return msg
+}
+
+func (f *fakeAPI) sendMediaGroup(w http.ResponseWriter, r *http.Request) {
+	chat, _ := strconv.ParseInt(r.FormValue("chat_id"), 10, 64)
+	var media []struct {
+		Media   string `json:"media"`
+		Caption string `json:"caption"`
+	}
+	json.Unmarshal([]byte(r.FormValue("media")), &media)
+
+	s := sent{Method: "sendMediaGroup", Chat: chat}
+	for _, m := range media {
+		if s.Caption == "" {
+			s.Caption = m.Caption
+		}
+		s.Files = append(s.Files, readFile(r, strings.TrimPrefix(m.Media, "attach://")))
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var res []*echotron.Message
+	for range media {
+		f.msgID++
+		res = append(res, &echotron.Message{ID: f.msgID, Chat: echotron.Chat{ID: chat}, Date: int(time.Now().Unix())})
+	}
+	f.sent[chat] = append(f.sent[chat], s)
+	f.notify()
+	f.reply(w, res)
+}
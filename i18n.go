@@ -0,0 +1,440 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const defaultLanguage = "en"
+
+// languageNames are shown in /language, keys are Telegram language codes.
+var languageNames = map[string]string{
+	"en": "English 🇬🇧",
+	"lv": "Latviešu 🇱🇻",
+	"ru": "Русский 🇷🇺",
+}
+
+// messages is the catalog of every user facing text. Format verbs are filled
+// by tr in the order documented for the english text.
+var messages = map[string]map[string]string{
+	"en": {
+		"hello":              "Hello %v 🖐,I am ready to take some photos 📷. Please send me your password😉",
+		"welcome":            "Welcome back, %v, I am ready to work, please send me a \"/photo\" command to take a picture 🖼",
+		"cancelled":          "%v, request cancelled ❌",
+		"cancel_none":        "%v, there is nothing to cancel 🤷",
+		"prompt_expired":     "%v, ⌛ no answer received, request cancelled. Send the command again when ready.",
+		"group_only":         "%v, this command works only in groups 👥",
+		"group_login":        "%v, please log in with me in private chat @%v first 🔐",
+		"role_usage":         "%v, reply to a message of group member with \"/role guest|member|none\" 👥",
+		"role_set":           "%v, %v now has role %v in this group 👥",
+		"capture_current":    "%v, camera can be moved by role %v and above 🎛",
+		"capture_usage":      "%v, please use \"/capture guest|member|admin\" 🎛",
+		"command_usage":      "%v, please use \"%v\" 📝",
+		"capture_set":        "%v, camera now can be moved by role %v and above 🎛",
+		"capture_denied":     "%v, camera in this group can not be moved by you 🎛",
+		"status":             "📊 Status\n⏱ Uptime: %v\n💬 Active sessions: %v\n🔐 Guest pass expires: %v\n🌆 Sunset today: %v\n\n%v\n\n%v",
+		"status_camera":      "📷 %v: X: %v Y: %v, queue %v/%v, last photo %v, last error %v",
+		"status_job":         "    • %v X: %v Y: %v, waiting %v",
+		"status_event":       "🎉 %v, %v X: %v Y: %v at %v",
+		"status_no_events":   "🎉 No scheduled events",
+		"status_never":       "never",
+		"cancel_button":      "❌ Cancel",
+		"job_cancelled":      "Photo #%v removed from the queue ❌",
+		"job_started":        "Photo #%v is already being taken 📷",
+		"job_unknown":        "There is no photo #%v of yours in the queue 🤷",
+		"queue_none":         "%v, you have no photos in the queue 🤷",
+		"queue_list":         "%v, your photos in the queue 🕙\n%v",
+		"queue_job":          "#%v %v X: %v Y: %v, place %v",
+		"queue_job_running":  "#%v %v X: %v Y: %v, taking now 📷",
+		"shutdown_pending":   "🔌 Bot is restarting, %v of your queued photos will be taken after restart 🕙",
+		"shutting_down":      "🔌 Bot is restarting, please try again in a minute 🕙",
+		"unknown_command":    "%v, I dont understand command: %v",
+		"cmd_help":           "Get a list of commands 📜",
+		"cmd_photo":          "Take a photo from camera 📷",
+		"cmd_clip":           "Record a video clip 🎬",
+		"cmd_dice":           "Throw a dice and take a photo 🎲",
+		"cmd_camera":         "Choose default camera 🎥",
+		"cmd_where":          "Get camera position 🧭",
+		"cmd_eventcreate":    "Create an event 🎉",
+		"cmd_eventdelete":    "Delete an event 🔴",
+		"cmd_eventsunset":    "Create sunset event 🌆",
+		"cmd_sunsettime":     "Get sunset time 🌆🕙",
+		"cmd_guestpass":      "Get guest password 🔐",
+		"cmd_zones":          "List private zones 🔒",
+		"cmd_zoneadd":        "Add private zone 🔒",
+		"cmd_zonedel":        "Delete private zone 🔓",
+		"cmd_role":           "Set role of replied group member 👥",
+		"cmd_capture":        "Set who may move camera in group 🎛",
+		"cmd_status":         "Bot status dashboard 📊",
+		"cmd_queue":          "Show your queued photos 🕙",
+		"cmd_cancel":         "Cancel pending request or queued photo ❌",
+		"cmd_language":       "Choose language 🌐",
+		"guest_denied":       "%v, you can not do that as guest [🛑]",
+		"photo_prompt":       "%v, please specify coordinates X Y 🕹 in degrees (or +X -Y to move relative) to turn camera 📷 and take a picture 🖼",
+		"photo_invalid":      "%v, please specify coordinates X Y 🕹 in degrees to turn camera 📷",
+		"photo_queued":       "%v, added your request to the queue as #%v, please wait 🕙",
+		"clip_prompt":        "%v, enter X Y coordinates and clip length in seconds (up to %v) 🎬",
+		"clip_invalid":       "%v, please use format \"X Y seconds\" 🕹",
+		"clip_length":        "%v, clip can be 1 to %v seconds long 🎬",
+		"clip_masked":        "%v, camera %v can not record clips at X: %v Y: %v, part of the view is private [🛑]",
+		"clip_queued":        "%v, recording %v second clip 🎬 #%v, please wait",
+		"clip_failed":        "Cant record clip [🛑], try again later 🕙",
+		"x_range":            "%v, X coordinate should be greater than 0, but smaller than 360 [🛑]",
+		"y_range":            "%v, Y coordinate should be greater than 0, but smaller than 90 [🛑]",
+		"queue_full":         "Sorry, queue is full. Try again later 🕙",
+		"camera_unavailable": "Camera is temporarily unavailable [🛑], try again later 🕙",
+		"camera_current":     "%v, your camera is %v 🎥, available cameras: %v. Send \"/camera name\" to change it",
+		"camera_set":         "%v, now using camera %v 🎥",
+		"camera_unknown":     "%v, there is no camera %v [🛑], available cameras: %v",
+		"where":              "%v, cameras are looking at 🧭\n%v",
+		"dice_reroll":        "X: %v Y: %v is a private zone, rolling again 🎲",
+		"dice_failed":        "%v, dice keep landing in private zones, try again later 🎲",
+		"dice_photo":         "%v, doing photo 🖼 on coordinates X: %v Y: %v as #%v, please wait 🕙",
+		"event_prompt":       "%v, event will send you photo 🖼 everyday at exact time, to create an event send information in format \"X Y HH:MM\" 😁",
+		"event_invalid":      "%v, please specify valid info in format \"[camera] X Y HH:MM\" to create an event 📷",
+		"hours_negative":     "%v, hours cant be negative number [🛑]",
+		"minutes_negative":   "%v, minutes cant be negative number [🛑]",
+		"event_created":      "%v, event (%v X: %v Y: %v %v:%v Sunset:%v) created 🎉",
+		"event_exists":       "%v, delete your existing event first (X: %v Y: %v %v:%v Sunset:%v) 🎉",
+		"event_none":         "%v, you have no existing event [🛑]",
+		"event_deleted":      "%v, deleted your existing event (X: %v Y: %v %v:%v Sunset:%v) 🎉",
+		"sunset_exists":      "%v, please delete your existing event first 🎉",
+		"sunset_prompt":      "Enter X and Y coordinate to create sunset event 🌆, optionally add weather conditions like clouds<80 or visibility>10",
+		"sunset_count":       "%v, please enter two coordinates 🕹",
+		"sunset_invalid":     "%v, please specify valid coordinates X Y 🕹 in degrees to create an event 📷",
+		"sunset_created":     "Created sunset 🌆 event at camera %v coordinates %v %v",
+		"sunset_created_if":  "Created sunset 🌆 event at camera %v coordinates %v %v, taken only if %v",
+		"condition_invalid":  "%v, I dont understand weather condition %v [🛑], use clouds<N (percent) or visibility>N (km)",
+		"cond_clouds":        "cloud cover is %v%% (wanted %v%v%%)",
+		"cond_visibility":    "visibility is %v km (wanted %v%v km)",
+		"event_skipped":      "Sunset 🌆 photo at camera %v skipped because of weather ☁️: %v",
+		"alert_weather":      "⚠️ Failed to get weather, event photos are taken regardless: %v",
+		"sunset_time":        "%v, today you can see sunset at %v:%v",
+		"guestpass":          "%v, guest password 🔐 for next 8 hours is %v",
+		"zone_forbidden":     "%v, camera %v is not allowed to look at X: %v Y: %v, it is a private zone [🛑]",
+		"zone_dropped":       "Camera %v is not allowed to look at X: %v Y: %v, it is a private zone [🛑]",
+		"zones_none":         "%v, camera %v has no private zones 🔓",
+		"zones_list":         "%v, private zones of camera %v 🔒\n%v",
+		"zone_added":         "%v, added private zone %v to camera %v 🔒",
+		"zonedel_usage":      "%v, please use format \"/zonedel [camera] N\", see /zones for numbers 🕹",
+		"zone_deleted":       "%v, deleted private zone %v of camera %v 🔓",
+		"motor_failed":       "Cant access motor_driver [🛑], try again later 🕑",
+		"photo_failed":       "Cant get photo [🛑], try again later 🕙",
+		"process_failed":     "Cant process photo [🛑], try again later 🕙",
+		"send_failed":        "Cant send photo [🛑], try again later 🕞",
+		"breaker_open":       "Camera %v failed %v times in a row [🛑], rejecting photo requests for %v. Last error: %v",
+		"alert_motor":        "⚠️ motor_driver of camera %v failed, homing it: %v",
+		"alert_phone_init":   "⚠️ Camera %v did not return a photo, running phone_init: %v",
+		"alert_sunset":       "⚠️ Failed to fetch sunset time 🌆: %v",
+		"alert_poll":         "⚠️ Failed to receive updates from Telegram: %v",
+		"alert_resolved":     "✅ Resolved after %[2]v (%[3]v times): %[1]v",
+		"language_current":   "%v, your language is %v, available: %v. Send \"/language code\" to change it",
+		"language_set":       "%v, I will speak English now 🇬🇧",
+		"language_unknown":   "%v, I dont know language %v [🛑], available: %v",
+	},
+	"lv": {
+		"hello":              "Sveiki, %v 🖐, esmu gatavs fotografēt 📷. Lūdzu, atsūti man paroli😉",
+		"welcome":            "Laipni lūgts atpakaļ, %v, esmu gatavs darbam, sūti komandu \"/photo\", lai uzņemtu bildi 🖼",
+		"cancelled":          "%v, pieprasījums atcelts ❌",
+		"cancel_none":        "%v, nav ko atcelt 🤷",
+		"prompt_expired":     "%v, ⌛ atbilde netika saņemta, pieprasījums atcelts. Sūti komandu vēlreiz, kad esi gatavs.",
+		"group_only":         "%v, šī komanda darbojas tikai grupās 👥",
+		"group_login":        "%v, lūdzu, vispirms pieslēdzies privātā čatā ar @%v 🔐",
+		"role_usage":         "%v, atbildi uz grupas dalībnieka ziņu ar \"/role guest|member|none\" 👥",
+		"role_set":           "%v, %v tagad šajā grupā ir loma %v 👥",
+		"capture_current":    "%v, kameru var grozīt loma %v un augstāk 🎛",
+		"capture_usage":      "%v, lūdzu, izmanto \"/capture guest|member|admin\" 🎛",
+		"command_usage":      "%v, lūdzu, izmanto \"%v\" 📝",
+		"capture_set":        "%v, tagad kameru var grozīt loma %v un augstāk 🎛",
+		"capture_denied":     "%v, tu šajā grupā nevari grozīt kameru 🎛",
+		"status":             "📊 Statuss\n⏱ Darbības laiks: %v\n💬 Aktīvās sesijas: %v\n🔐 Viesa parole beigsies: %v\n🌆 Saulriets šodien: %v\n\n%v\n\n%v",
+		"status_camera":      "📷 %v: X: %v Y: %v, rinda %v/%v, pēdējā bilde %v, pēdējā kļūda %v",
+		"status_job":         "    • %v X: %v Y: %v, gaida %v",
+		"status_event":       "🎉 %v, %v X: %v Y: %v plkst. %v",
+		"status_no_events":   "🎉 Nav ieplānotu notikumu",
+		"status_never":       "nekad",
+		"cancel_button":      "❌ Atcelt",
+		"job_cancelled":      "Bilde #%v izņemta no rindas ❌",
+		"job_started":        "Bilde #%v jau tiek uzņemta 📷",
+		"job_unknown":        "Rindā nav tavas bildes #%v 🤷",
+		"queue_none":         "%v, tev nav bilžu rindā 🤷",
+		"queue_list":         "%v, tavas bildes rindā 🕙\n%v",
+		"queue_job":          "#%v %v X: %v Y: %v, vieta %v",
+		"queue_job_running":  "#%v %v X: %v Y: %v, tiek uzņemta 📷",
+		"shutdown_pending":   "🔌 Bots tiek restartēts, %v tavas bildes rindā tiks uzņemtas pēc restarta 🕙",
+		"shutting_down":      "🔌 Bots tiek restartēts, lūdzu, mēģini pēc minūtes 🕙",
+		"unknown_command":    "%v, es nesaprotu komandu: %v",
+		"cmd_help":           "Komandu saraksts 📜",
+		"cmd_photo":          "Uzņemt bildi ar kameru 📷",
+		"cmd_clip":           "Ierakstīt video klipu 🎬",
+		"cmd_dice":           "Mest kauliņu un uzņemt bildi 🎲",
+		"cmd_camera":         "Izvēlēties kameru 🎥",
+		"cmd_where":          "Kameras pozīcija 🧭",
+		"cmd_eventcreate":    "Izveidot notikumu 🎉",
+		"cmd_eventdelete":    "Dzēst notikumu 🔴",
+		"cmd_eventsunset":    "Izveidot saulrieta notikumu 🌆",
+		"cmd_sunsettime":     "Saulrieta laiks 🌆🕙",
+		"cmd_guestpass":      "Viesa parole 🔐",
+		"cmd_zones":          "Privātās zonas 🔒",
+		"cmd_zoneadd":        "Pievienot privāto zonu 🔒",
+		"cmd_zonedel":        "Dzēst privāto zonu 🔓",
+		"cmd_role":           "Iestatīt grupas dalībnieka lomu 👥",
+		"cmd_capture":        "Iestatīt, kas grupā var grozīt kameru 🎛",
+		"cmd_status":         "Bota statusa pārskats 📊",
+		"cmd_queue":          "Tavas bildes rindā 🕙",
+		"cmd_cancel":         "Atcelt gaidošo pieprasījumu vai bildi rindā ❌",
+		"cmd_language":       "Izvēlēties valodu 🌐",
+		"guest_denied":       "%v, viesis to nevar darīt [🛑]",
+		"photo_prompt":       "%v, lūdzu, norādi koordinātas X Y 🕹 grādos (vai +X -Y relatīvai kustībai), lai pagrieztu kameru 📷 un uzņemtu bildi 🖼",
+		"photo_invalid":      "%v, lūdzu, norādi koordinātas X Y 🕹 grādos, lai pagrieztu kameru 📷",
+		"photo_queued":       "%v, tavs pieprasījums pievienots rindai kā #%v, lūdzu, uzgaidi 🕙",
+		"clip_prompt":        "%v, ievadi X Y koordinātas un klipa garumu sekundēs (līdz %v) 🎬",
+		"clip_invalid":       "%v, lūdzu, lieto formātu \"X Y sekundes\" 🕹",
+		"clip_length":        "%v, klips var būt no 1 līdz %v sekundēm 🎬",
+		"clip_masked":        "%v, kamera %v nevar ierakstīt klipus pie X: %v Y: %v, daļa skata ir privāta [🛑]",
+		"clip_queued":        "%v, ierakstu %v sekunžu klipu 🎬 #%v, lūdzu, uzgaidi",
+		"clip_failed":        "Nevar ierakstīt klipu [🛑], mēģini vēlāk 🕙",
+		"x_range":            "%v, X koordinātai jābūt no 0 līdz 360 [🛑]",
+		"y_range":            "%v, Y koordinātai jābūt no 0 līdz 90 [🛑]",
+		"queue_full":         "Atvaino, rinda ir pilna. Mēģini vēlāk 🕙",
+		"camera_unavailable": "Kamera īslaicīgi nav pieejama [🛑], mēģini vēlāk 🕙",
+		"camera_current":     "%v, tava kamera ir %v 🎥, pieejamās kameras: %v. Sūti \"/camera nosaukums\", lai to mainītu",
+		"camera_set":         "%v, tagad izmantoju kameru %v 🎥",
+		"camera_unknown":     "%v, kameras %v nav [🛑], pieejamās kameras: %v",
+		"where":              "%v, kameras skatās uz 🧭\n%v",
+		"dice_reroll":        "X: %v Y: %v ir privāta zona, metu vēlreiz 🎲",
+		"dice_failed":        "%v, kauliņi visu laiku trāpa privātās zonās, mēģini vēlāk 🎲",
+		"dice_photo":         "%v, uzņemu bildi 🖼 koordinātās X: %v Y: %v kā #%v, lūdzu, uzgaidi 🕙",
+		"event_prompt":       "%v, notikums katru dienu noteiktā laikā sūtīs tev bildi 🖼, lai to izveidotu, sūti informāciju formātā \"X Y HH:MM\" 😁",
+		"event_invalid":      "%v, lūdzu, norādi informāciju formātā \"[kamera] X Y HH:MM\", lai izveidotu notikumu 📷",
+		"hours_negative":     "%v, stundas nevar būt negatīvas [🛑]",
+		"minutes_negative":   "%v, minūtes nevar būt negatīvas [🛑]",
+		"event_created":      "%v, notikums (%v X: %v Y: %v %v:%v Saulriets:%v) izveidots 🎉",
+		"event_exists":       "%v, vispirms izdzēs esošo notikumu (X: %v Y: %v %v:%v Saulriets:%v) 🎉",
+		"event_none":         "%v, tev nav neviena notikuma [🛑]",
+		"event_deleted":      "%v, tavs notikums dzēsts (X: %v Y: %v %v:%v Saulriets:%v) 🎉",
+		"sunset_exists":      "%v, lūdzu, vispirms izdzēs esošo notikumu 🎉",
+		"sunset_prompt":      "Ievadi X un Y koordinātas, lai izveidotu saulrieta notikumu 🌆, vari pievienot laikapstākļu nosacījumus, piemēram, clouds<80 vai visibility>10",
+		"sunset_count":       "%v, lūdzu, ievadi divas koordinātas 🕹",
+		"sunset_invalid":     "%v, lūdzu, norādi derīgas koordinātas X Y 🕹 grādos, lai izveidotu notikumu 📷",
+		"sunset_created":     "Izveidots saulrieta 🌆 notikums kamerai %v koordinātās %v %v",
+		"sunset_created_if":  "Izveidots saulrieta 🌆 notikums kamerai %v koordinātās %v %v, tiks uzņemts tikai ja %v",
+		"condition_invalid":  "%v, nesaprotu laikapstākļu nosacījumu %v [🛑], lieto clouds<N (procenti) vai visibility>N (km)",
+		"cond_clouds":        "mākoņainība ir %v%% (vajag %v%v%%)",
+		"cond_visibility":    "redzamība ir %v km (vajag %v%v km)",
+		"event_skipped":      "Saulrieta 🌆 bilde kamerai %v izlaista laikapstākļu dēļ ☁️: %v",
+		"alert_weather":      "⚠️ Neizdevās iegūt laikapstākļus, notikumu bildes tiek uzņemtas tāpat: %v",
+		"sunset_time":        "%v, šodien saulriets būs %v:%v",
+		"guestpass":          "%v, viesa parole 🔐 nākamajām 8 stundām ir %v",
+		"zone_forbidden":     "%v, kamerai %v nav atļauts skatīties uz X: %v Y: %v, tā ir privāta zona [🛑]",
+		"zone_dropped":       "Kamerai %v nav atļauts skatīties uz X: %v Y: %v, tā ir privāta zona [🛑]",
+		"zones_none":         "%v, kamerai %v nav privāto zonu 🔓",
+		"zones_list":         "%v, kameras %v privātās zonas 🔒\n%v",
+		"zone_added":         "%v, privātā zona %v pievienota kamerai %v 🔒",
+		"zonedel_usage":      "%v, lūdzu, izmanto formātu \"/zonedel [kamera] N\", numurus skaties /zones 🕹",
+		"zone_deleted":       "%[1]v, kameras %[3]v privātā zona %[2]v dzēsta 🔓",
+		"motor_failed":       "Nevar piekļūt motor_driver [🛑], mēģini vēlāk 🕑",
+		"photo_failed":       "Nevar iegūt bildi [🛑], mēģini vēlāk 🕙",
+		"process_failed":     "Nevar apstrādāt bildi [🛑], mēģini vēlāk 🕙",
+		"send_failed":        "Nevar nosūtīt bildi [🛑], mēģini vēlāk 🕞",
+		"breaker_open":       "Kamera %v kļūdījās %v reizes pēc kārtas [🛑], pieprasījumi tiks noraidīti %v. Pēdējā kļūda: %v",
+		"alert_motor":        "⚠️ Kameras %v motor_driver kļūda, atgriežu sākumpozīcijā: %v",
+		"alert_phone_init":   "⚠️ Kamera %v neatdeva bildi, palaižu phone_init: %v",
+		"alert_sunset":       "⚠️ Neizdevās iegūt saulrieta laiku 🌆: %v",
+		"alert_poll":         "⚠️ Neizdevās saņemt atjauninājumus no Telegram: %v",
+		"alert_resolved":     "✅ Novērsts pēc %[2]v (%[3]v reizes): %[1]v",
+		"language_current":   "%v, tava valoda ir %v, pieejamās: %v. Sūti \"/language kods\", lai to mainītu",
+		"language_set":       "%v, tagad runāšu latviski 🇱🇻",
+		"language_unknown":   "%v, es nezinu valodu %v [🛑], pieejamās: %v",
+	},
+	"ru": {
+		"hello":              "Привет, %v 🖐, я готов делать фото 📷. Пожалуйста, отправь мне пароль😉",
+		"welcome":            "С возвращением, %v, я готов к работе, отправь команду \"/photo\", чтобы сделать снимок 🖼",
+		"cancelled":          "%v, запрос отменён ❌",
+		"cancel_none":        "%v, нечего отменять 🤷",
+		"prompt_expired":     "%v, ⌛ ответ не получен, запрос отменён. Отправь команду ещё раз, когда будешь готов.",
+		"group_only":         "%v, эта команда работает только в группах 👥",
+		"group_login":        "%v, сначала войди в личном чате с @%v 🔐",
+		"role_usage":         "%v, ответь на сообщение участника группы командой \"/role guest|member|none\" 👥",
+		"role_set":           "%v, у %v теперь роль %v в этой группе 👥",
+		"capture_current":    "%v, камеру могут поворачивать роль %v и выше 🎛",
+		"capture_usage":      "%v, пожалуйста, используй \"/capture guest|member|admin\" 🎛",
+		"command_usage":      "%v, пожалуйста, используй \"%v\" 📝",
+		"capture_set":        "%v, теперь камеру могут поворачивать роль %v и выше 🎛",
+		"capture_denied":     "%v, ты не можешь поворачивать камеру в этой группе 🎛",
+		"status":             "📊 Состояние\n⏱ Время работы: %v\n💬 Активные сессии: %v\n🔐 Гостевой пароль истекает: %v\n🌆 Закат сегодня: %v\n\n%v\n\n%v",
+		"status_camera":      "📷 %v: X: %v Y: %v, очередь %v/%v, последнее фото %v, последняя ошибка %v",
+		"status_job":         "    • %v X: %v Y: %v, ждёт %v",
+		"status_event":       "🎉 %v, %v X: %v Y: %v в %v",
+		"status_no_events":   "🎉 Нет запланированных событий",
+		"status_never":       "никогда",
+		"cancel_button":      "❌ Отменить",
+		"job_cancelled":      "Фото #%v убрано из очереди ❌",
+		"job_started":        "Фото #%v уже снимается 📷",
+		"job_unknown":        "В очереди нет твоего фото #%v 🤷",
+		"queue_none":         "%v, у тебя нет фото в очереди 🤷",
+		"queue_list":         "%v, твои фото в очереди 🕙\n%v",
+		"queue_job":          "#%v %v X: %v Y: %v, место %v",
+		"queue_job_running":  "#%v %v X: %v Y: %v, снимается сейчас 📷",
+		"shutdown_pending":   "🔌 Бот перезапускается, фото в очереди (%v) будут сняты после перезапуска 🕙",
+		"shutting_down":      "🔌 Бот перезапускается, пожалуйста, попробуй через минуту 🕙",
+		"unknown_command":    "%v, я не понимаю команду: %v",
+		"cmd_help":           "Список команд 📜",
+		"cmd_photo":          "Сделать фото с камеры 📷",
+		"cmd_clip":           "Записать видеоклип 🎬",
+		"cmd_dice":           "Бросить кубик и сделать фото 🎲",
+		"cmd_camera":         "Выбрать камеру 🎥",
+		"cmd_where":          "Положение камеры 🧭",
+		"cmd_eventcreate":    "Создать событие 🎉",
+		"cmd_eventdelete":    "Удалить событие 🔴",
+		"cmd_eventsunset":    "Создать событие на закат 🌆",
+		"cmd_sunsettime":     "Время заката 🌆🕙",
+		"cmd_guestpass":      "Гостевой пароль 🔐",
+		"cmd_zones":          "Приватные зоны 🔒",
+		"cmd_zoneadd":        "Добавить приватную зону 🔒",
+		"cmd_zonedel":        "Удалить приватную зону 🔓",
+		"cmd_role":           "Назначить роль участнику группы 👥",
+		"cmd_capture":        "Кто в группе может поворачивать камеру 🎛",
+		"cmd_status":         "Панель состояния бота 📊",
+		"cmd_queue":          "Твои фото в очереди 🕙",
+		"cmd_cancel":         "Отменить запрос или фото в очереди ❌",
+		"cmd_language":       "Выбрать язык 🌐",
+		"guest_denied":       "%v, гостям это недоступно [🛑]",
+		"photo_prompt":       "%v, пожалуйста, укажи координаты X Y 🕹 в градусах (или +X -Y для относительного поворота), чтобы повернуть камеру 📷 и сделать снимок 🖼",
+		"photo_invalid":      "%v, пожалуйста, укажи координаты X Y 🕹 в градусах, чтобы повернуть камеру 📷",
+		"photo_queued":       "%v, запрос добавлен в очередь как #%v, пожалуйста, подожди 🕙",
+		"clip_prompt":        "%v, введи координаты X Y и длину клипа в секундах (до %v) 🎬",
+		"clip_invalid":       "%v, пожалуйста, используй формат \"X Y секунды\" 🕹",
+		"clip_length":        "%v, клип может длиться от 1 до %v секунд 🎬",
+		"clip_masked":        "%v, камера %v не может записывать клипы на X: %v Y: %v, часть вида приватна [🛑]",
+		"clip_queued":        "%v, записываю клип на %v секунд 🎬 #%v, подожди",
+		"clip_failed":        "Не удалось записать клип [🛑], попробуй позже 🕙",
+		"x_range":            "%v, координата X должна быть от 0 до 360 [🛑]",
+		"y_range":            "%v, координата Y должна быть от 0 до 90 [🛑]",
+		"queue_full":         "Извини, очередь заполнена. Попробуй позже 🕙",
+		"camera_unavailable": "Камера временно недоступна [🛑], попробуй позже 🕙",
+		"camera_current":     "%v, твоя камера %v 🎥, доступные камеры: %v. Отправь \"/camera имя\", чтобы сменить её",
+		"camera_set":         "%v, теперь используется камера %v 🎥",
+		"camera_unknown":     "%v, камеры %v нет [🛑], доступные камеры: %v",
+		"where":              "%v, камеры смотрят на 🧭\n%v",
+		"dice_reroll":        "X: %v Y: %v - приватная зона, бросаю ещё раз 🎲",
+		"dice_failed":        "%v, кубики всё время попадают в приватные зоны, попробуй позже 🎲",
+		"dice_photo":         "%v, делаю фото 🖼 по координатам X: %v Y: %v как #%v, пожалуйста, подожди 🕙",
+		"event_prompt":       "%v, событие будет присылать тебе фото 🖼 каждый день в заданное время, чтобы создать его, отправь данные в формате \"X Y ЧЧ:ММ\" 😁",
+		"event_invalid":      "%v, пожалуйста, укажи данные в формате \"[камера] X Y ЧЧ:ММ\", чтобы создать событие 📷",
+		"hours_negative":     "%v, часы не могут быть отрицательными [🛑]",
+		"minutes_negative":   "%v, минуты не могут быть отрицательными [🛑]",
+		"event_created":      "%v, событие (%v X: %v Y: %v %v:%v Закат:%v) создано 🎉",
+		"event_exists":       "%v, сначала удали существующее событие (X: %v Y: %v %v:%v Закат:%v) 🎉",
+		"event_none":         "%v, у тебя нет событий [🛑]",
+		"event_deleted":      "%v, событие удалено (X: %v Y: %v %v:%v Закат:%v) 🎉",
+		"sunset_exists":      "%v, пожалуйста, сначала удали существующее событие 🎉",
+		"sunset_prompt":      "Введи координаты X и Y, чтобы создать событие на закат 🌆, можно добавить погодные условия, например clouds<80 или visibility>10",
+		"sunset_count":       "%v, пожалуйста, введи две координаты 🕹",
+		"sunset_invalid":     "%v, пожалуйста, укажи правильные координаты X Y 🕹 в градусах, чтобы создать событие 📷",
+		"sunset_created":     "Создано событие на закат 🌆 для камеры %v по координатам %v %v",
+		"sunset_created_if":  "Создано событие на закат 🌆 для камеры %v по координатам %v %v, снимается только если %v",
+		"condition_invalid":  "%v, не понимаю погодное условие %v [🛑], используй clouds<N (проценты) или visibility>N (км)",
+		"cond_clouds":        "облачность %v%% (нужно %v%v%%)",
+		"cond_visibility":    "видимость %v км (нужно %v%v км)",
+		"event_skipped":      "Фото заката 🌆 с камеры %v пропущено из-за погоды ☁️: %v",
+		"alert_weather":      "⚠️ Не удалось получить погоду, фото событий снимаются всё равно: %v",
+		"sunset_time":        "%v, сегодня закат в %v:%v",
+		"guestpass":          "%v, гостевой пароль 🔐 на следующие 8 часов: %v",
+		"zone_forbidden":     "%v, камере %v нельзя смотреть на X: %v Y: %v, это приватная зона [🛑]",
+		"zone_dropped":       "Камере %v нельзя смотреть на X: %v Y: %v, это приватная зона [🛑]",
+		"zones_none":         "%v, у камеры %v нет приватных зон 🔓",
+		"zones_list":         "%v, приватные зоны камеры %v 🔒\n%v",
+		"zone_added":         "%v, приватная зона %v добавлена камере %v 🔒",
+		"zonedel_usage":      "%v, пожалуйста, используй формат \"/zonedel [камера] N\", номера смотри в /zones 🕹",
+		"zone_deleted":       "%v, приватная зона %v камеры %v удалена 🔓",
+		"motor_failed":       "Нет доступа к motor_driver [🛑], попробуй позже 🕑",
+		"photo_failed":       "Не удалось получить фото [🛑], попробуй позже 🕙",
+		"process_failed":     "Не удалось обработать фото [🛑], попробуй позже 🕙",
+		"send_failed":        "Не удалось отправить фото [🛑], попробуй позже 🕞",
+		"breaker_open":       "Камера %v дала сбой %v раз подряд [🛑], запросы будут отклоняться %v. Последняя ошибка: %v",
+		"alert_motor":        "⚠️ Сбой motor_driver камеры %v, возвращаю в начальное положение: %v",
+		"alert_phone_init":   "⚠️ Камера %v не вернула фото, запускаю phone_init: %v",
+		"alert_sunset":       "⚠️ Не удалось получить время заката 🌆: %v",
+		"alert_poll":         "⚠️ Не удалось получить обновления от Telegram: %v",
+		"alert_resolved":     "✅ Устранено через %[2]v (%[3]v раз): %[1]v",
+		"language_current":   "%v, твой язык %v, доступные: %v. Отправь \"/language код\", чтобы сменить его",
+		"language_set":       "%v, теперь я говорю по-русски 🇷🇺",
+		"language_unknown":   "%v, я не знаю язык %v [🛑], доступные: %v",
+	},
+}
+
+// tr returns message key in language lang, falling back to english.
+func tr(lang, key string, args ...interface{}) string {
+	format, ok := messages[lang][key]
+	if !ok {
+		format, ok = messages[defaultLanguage][key]
+	}
+	if !ok {
+		log.Error().Str("key", key).Msg("Missing message in catalog.")
+		return key
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// normalizeLanguage maps Telegram language_code like "ru-RU" to catalog language.
+func normalizeLanguage(code string) string {
+	code = strings.ToLower(code)
+	if i := strings.IndexAny(code, "-_"); i >= 0 {
+		code = code[:i]
+	}
+	if _, ok := messages[code]; ok {
+		return code
+	}
+	return defaultLanguage
+}
+
+// userLanguage returns language chosen with /language or the one from Telegram client.
+func userLanguage(userID int64, code string) string {
+	var lang string
+	viewStore(func(p *persistent) {
+		lang = p.Languages[userID]
+	})
+	if lang != "" {
+		return lang
+	}
+	return normalizeLanguage(code)
+}
+
+func languageCodes() []string {
+	codes := make([]string, 0, len(languageNames))
+	for code := range languageNames {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+func (b *Bot) tr(key string, args ...interface{}) string {
+	return tr(b.language(), key, args...)
+}
+
+// language returns the language of the chat.
+func (b *Bot) language() string {
+	b.lmu.Lock()
+	defer b.lmu.Unlock()
+	return b.lang
+}
+
+func (b *Bot) setLanguage(code string) {
+	b.lmu.Lock()
+	b.lang = code
+	b.lmu.Unlock()
+}
+
+// reply sends message key translated to the language of the chat.
+func (b *Bot) reply(key string, args ...interface{}) {
+	if _, err := b.SendMessage(b.tr(key, args...), b.chatID, nil); err != nil {
+		log.Error().Err(err).Msg("Failed to send message.")
+		time.Sleep(10 * time.Second)
+	}
+}
@@ -7,7 +7,6 @@ import (
 	"math/rand"
 	"net/http"
 	"os"
-	"os/exec"
 	"strconv"
 	"strings"
 	"sync"
@@ -19,65 +18,126 @@ import (
 )
 
 type event struct {
-	X      int
-	Y      int
-	Hour   int
-	Minute int
-	Active bool
-	Sunset bool
+	X       int       `json:"x"`
+	Y       int       `json:"y"`
+	Hour    int       `json:"hour"`
+	Minute  int       `json:"minute"`
+	Sunset  bool      `json:"sunset"`
+	Owner   string    `json:"owner"`
+	OwnerID int64     `json:"owner_id"`
+	Camera  string    `json:"camera"`
+	LastRun time.Time `json:"last_run"`
+	// Conditions is the weather required to take the photo.
+	Conditions []condition `json:"conditions,omitempty"`
 }
 
 type Bot struct {
-	chatID  int64
-	isGuest bool
-	state   stateFn
-	Event   event
+	chatID int64
+	// group is set for group chats, which have negative IDs.
+	group bool
+	// lmu guards lang, which camera workers read to translate replies.
+	lmu   sync.Mutex
+	lang  string
+	users map[int64]*member
+	// user is the member whose update is being handled.
+	user *member
+	mu   sync.Mutex
 	echotron.API
-	mu sync.Mutex
 }
 
 type stateFn func(*echotron.Update) stateFn
 
-var dsp *echotron.Dispatcher
-var CameraPosX int
-var task_count int
 var weekday string = "BlaBlaDay"
-var hoursunset int
-var minutesunset int
-var guestpass string
+
+// sunsetDay is the weekday sunset time was last fetched on.
+var sunsetDay string
+
+// sunsetAt is today's sunset time, updated by LogsControl.
+var sunsetAt struct {
+	sync.Mutex
+	hour, minute int
+}
+
+// dayTime is a time of day.
+type dayTime struct {
+	Hour   int `json:"hour"`
+	Minute int `json:"minute"`
+}
+
+// guestPass is the current guest password, replaced by GenGuestPass.
+var guestPass struct {
+	sync.Mutex
+	pass   string
+	expiry time.Time
+}
+
 var randsrc *rand.Rand
+var cameraLat float64
+var cameraLng float64
+var sendDocument bool
+var botUsername string
 
 const queue_cap = 5
+const diceRolls = 3
 
-func newBot(chatID int64) echotron.Bot {
+// diceDelay waits for the dice animation before moving the camera.
+var diceDelay = 5 * time.Second
+
+func newBot(chatID int64) *Bot {
 	bot := &Bot{
 		chatID: chatID,
+		group:  chatID < 0,
+		users:  make(map[int64]*member),
 		API:    echotron.NewAPI(os.Getenv("TOKEN")),
 	}
 
-	bot.state = bot.handleMessage
-	go bot.selfDestruct(time.After(time.Hour * 8))
+	go bot.selfDestruct(clk.After(time.Hour * 8))
 	return bot
 }
 
 func (b *Bot) selfDestruct(timech <-chan time.Time) {
 	<-timech
-	if b.Event.Active {
-		b.state = b.handleMessage
-		go b.selfDestruct(time.After(time.Hour * 8))
-	} else {
-		dsp.DelSession(b.chatID)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for id, m := range b.users {
+		switch m.role {
+		case roleGuest:
+			removeGuest(id)
+		case roleAdmin:
+			removeAdminUser(id)
+		}
 	}
+	delSession(b)
 }
 
 func (b *Bot) Update(update *echotron.Update) {
+	if update.CallbackQuery != nil {
+		b.mu.Lock()
+		b.handleCallback(update.CallbackQuery)
+		b.mu.Unlock()
+		return
+	}
 	if update.Message == nil {
 		return
 	}
 
 	log.Info().Str("Text", update.Message.Text).Strs("user", []string{update.Message.From.FirstName, update.Message.From.LastName, update.Message.From.Username}).Msg("")
 
-	b.state = b.state(update)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.setLanguage(userLanguage(update.Message.From.ID, update.Message.From.LanguageCode))
+	b.user = b.member(update.Message.From)
+	// In groups only commands for this bot and prompt answers are handled.
+	if _, _, ok := parseCommand(update.Message.Text); b.group && !ok && !b.user.pending {
+		return
+	}
+
+	gen := b.stopPrompt()
+	b.user.state = b.user.state(update)
+	if gen == b.user.promptGen {
+		b.user.pending = false
+	}
 }
 
 func (b *Bot) handleEventCreate(update *echotron.Update) stateFn {
@@ -86,15 +146,31 @@ func (b *Bot) handleEventCreate(update *echotron.Update) stateFn {
 		return state
 	}
 
-	data := strings.Fields(update.Message.Text)
+	return b.createEvent(update, b.promptArgs(update))
+}
+
+// splitTime turns "HH:MM" arguments into separate hours and minutes.
+func splitTime(args []string) []string {
+	var out []string
+	for _, arg := range args {
+		if h, m, ok := strings.Cut(arg, ":"); ok {
+			out = append(out, h, m)
+		} else {
+			out = append(out, arg)
+		}
+	}
+	return out
+}
+
+func (b *Bot) createEvent(update *echotron.Update, args []string) stateFn {
+	cam, data, ok := b.cameraArg(update, splitTime(args), 4)
+	if !ok {
+		return b.await(b.handleEventCreate)
+	}
 	if len(data) != 4 {
 		log.Warn().Str("data", update.Message.Text).Msg("Coordinates and time are not 4 numbers.")
-		_, err := b.SendMessage(fmt.Sprintf("%v, please specify valid info in format \"X Y Hours Minutes\" to create an event 📷", update.Message.From.FirstName), b.chatID, nil)
-		if err != nil {
-			log.Error().Err(err).Msg("Failed to send message.")
-			time.Sleep(10 * time.Second)
-		}
-		return b.handleEventCreate
+		b.reply("event_invalid", update.Message.From.FirstName)
+		return b.await(b.handleEventCreate)
 	}
 	x, err := strconv.Atoi(data[0])
 	y, err2 := strconv.Atoi(data[1])
@@ -102,299 +178,131 @@ func (b *Bot) handleEventCreate(update *echotron.Update) stateFn {
 	minute, err4 := strconv.Atoi(data[3])
 	if err2 != nil || err != nil || err3 != nil || err4 != nil {
 		log.Warn().Strs("data", data).Msg("X, Y, Hour or Minute are not numbers.")
-		_, err := b.SendMessage(fmt.Sprintf("%v, please specify valid info in format \"X Y Hours Minutes\" to create an event 📷", update.Message.From.FirstName), b.chatID, nil)
-		if err != nil {
-			log.Error().Err(err).Msg("Failed to send message.")
-			time.Sleep(10 * time.Second)
-		}
-		return b.handleEventCreate
+		b.reply("event_invalid", update.Message.From.FirstName)
+		return b.await(b.handleEventCreate)
 	}
 
 	hour = hour % 24
 	minute = minute % 60
 	if hour < 0 {
 		log.Warn().Int("hour", hour).Msg("Hours are negative.")
-		_, err := b.SendMessage(fmt.Sprintf("%v, hours cant be negative number [🛑]", update.Message.From.FirstName), b.chatID, nil)
-		if err != nil {
-			log.Error().Err(err).Msg("Failed to send message.")
-			time.Sleep(10 * time.Second)
-		}
-		return b.handleEventCreate
+		b.reply("hours_negative", update.Message.From.FirstName)
+		return b.await(b.handleEventCreate)
 	} else if minute < 0 {
 		log.Warn().Int("minute", minute).Msg("Minutes are negative.")
-		_, err := b.SendMessage(fmt.Sprintf("%v, minutes cant be negative number [🛑]", update.Message.From.FirstName), b.chatID, nil)
-		if err != nil {
-			log.Error().Err(err).Msg("Failed to send message.")
-			time.Sleep(10 * time.Second)
-		}
-		return b.handleEventCreate
+		b.reply("minutes_negative", update.Message.From.FirstName)
+		return b.await(b.handleEventCreate)
 	}
 
 	if x < 0 || x > 360 {
 		log.Warn().Int("x", x).Msg("X is greater than 360 or negative.")
-		_, err := b.SendMessage(fmt.Sprintf("%v, X coordinate should be greater than 0, but smaller than 360 [🛑]", update.Message.From.FirstName), b.chatID, nil)
-		if err != nil {
-			log.Error().Err(err).Msg("Failed to send message.")
-			time.Sleep(10 * time.Second)
-		}
-		return b.handleEventCreate
+		b.reply("x_range", update.Message.From.FirstName)
+		return b.await(b.handleEventCreate)
 	} else if y < 0 || y > 90 {
 		log.Warn().Int("y", y).Msg("Y is greater than 90 or negative.")
-		_, err := b.SendMessage(fmt.Sprintf("%v, Y coordinate should be greater than 0, but smaller than 90 [🛑]", update.Message.From.FirstName), b.chatID, nil)
-		if err != nil {
-			log.Error().Err(err).Msg("Failed to send message.")
-			time.Sleep(10 * time.Second)
-		}
-		return b.handleEventCreate
-	}
-
-	_, err = b.SendMessage(fmt.Sprintf("%v, event (X: %v Y: %v %v:%v Sunset:%v) created 🎉", update.Message.From.FirstName, x, y, hour, minute, b.Event.Sunset), b.chatID, nil)
-	if err != nil {
-		log.Error().Err(err).Msg("Failed to send message.")
-		time.Sleep(10 * time.Second)
+		b.reply("y_range", update.Message.From.FirstName)
+		return b.await(b.handleEventCreate)
+	} else if cam.forbidden(x, y) {
+		b.forbiddenZone(update, cam, x, y)
+		return b.await(b.handleEventCreate)
 	}
 
-	b.Event = event{X: x, Y: y, Hour: hour, Minute: minute, Active: true}
+	b.reply("event_created", update.Message.From.FirstName, cam.Name, x, y, hour, minute, false)
 
-	log.Info().Strs("user", []string{update.Message.From.FirstName, update.Message.From.LastName, update.Message.From.Username}).Ints("cords", []int{x, y}).Ints("time", []int{hour, minute}).Msg("Created event.")
+	addEvent(b.chatID, event{X: x, Y: y, Hour: hour, Minute: minute, Owner: userName(update.Message.From), OwnerID: update.Message.From.ID, Camera: cam.Name})
 
-	go b.runEvent()
+	log.Info().Strs("user", []string{update.Message.From.FirstName, update.Message.From.LastName, update.Message.From.Username}).Str("camera", cam.Name).Ints("cords", []int{x, y}).Ints("time", []int{hour, minute}).Msg("Created event.")
 
 	return b.handleLogin
 }
 
-func (b *Bot) runEvent() {
-	for range time.Tick(time.Second) {
-		timenow := time.Now()
-		if !b.Event.Sunset && timenow.Hour() == b.Event.Hour && timenow.Minute() == b.Event.Minute && timenow.Second() == 0 {
-			task_count++
-			log.Info().Ints("cords", []int{b.Event.X, b.Event.Y}).Msg("Doing event photo.")
-			go b.AccessCamera(b.Event.X, b.Event.Y)
-		} else if !b.Event.Active {
-			log.Info().Msg("Aborting event.")
-			return
-		} else if b.Event.Sunset && timenow.Hour() == hoursunset && timenow.Minute() == minutesunset && timenow.Second() == 0 {
-			task_count++
-			log.Info().Ints("cords", []int{b.Event.X, b.Event.Y}).Msg("Doing sunset event photo.")
-			go b.AccessCamera(b.Event.X, b.Event.Y)
-		}
+func GenGuestPass(dur time.Duration) {
+	for {
+		<-clk.After(dur)
+		newGuestPass(dur)
+		pass, _ := currentGuestPass()
+		log.Info().Str("password", pass).Msg("generated new guest password.")
 	}
 }
 
-func (b *Bot) checkCommands(update *echotron.Update) (stateFn, bool) {
-	if update.Message.Text == "/help" && b.isGuest {
-		if _, err := b.SendMessage("/help - Get a list of commands 📜\n/photo - Take a photo from camera 📷\n/dice - Throw a dice and take a photo 🎲\n/sunsettime - Get sunset time 🌆🕘", b.chatID, nil); err != nil {
-			log.Error().Err(err).Msg("Failed to send message.")
-			time.Sleep(10 * time.Second)
-		}
-		return b.handleLogin, true
-	} else if update.Message.Text == "/help" {
-		_, err := b.SendMessage("/help -  Get a list of commands 📜\n/photo - Take a photo from camera 📷\n/dice - Throw a dice and take a photo 🎲\n/eventcreate - Create an event 🎉\n/eventdelete - Delete an event 🔴\n/eventsunset - Create sunset event 🌆\n/sunsettime - Get sunset time 🌆🕙\n/guestpass - Get guest password 🔐", b.chatID, nil)
-		if err != nil {
-			log.Error().Err(err).Msg("Failed to send message.")
-			time.Sleep(10 * time.Second)
-		}
-		return b.handleLogin, true
-	} else if update.Message.Text == "/photo" {
-		_, err := b.SendMessage(fmt.Sprintf("%v, please specify coordinates X Y 🕹 in degrees to turn camera 📷 and take a picture 🖼", update.Message.From.FirstName), b.chatID, nil)
-		if err != nil {
-			log.Error().Err(err).Msg("Failed to send message.")
-			time.Sleep(10 * time.Second)
-		}
-		return b.handlePhoto, true
-	} else if update.Message.Text == "/dice" {
-		data, err := b.SendDice(b.chatID, "🎲", nil)
-		if err != nil {
-			log.Error().Err(err).Msg("Failed to send dice.")
-			time.Sleep(10 * time.Second)
-			return b.handleLogin, true
-		}
-		data2, err := b.SendDice(b.chatID, "🎲", nil)
-		if err != nil {
-			log.Error().Err(err).Msg("Failed to send dice.")
-			time.Sleep(10 * time.Second)
-			return b.handleLogin, true
-		}
-		if task_count+1 > queue_cap {
-			_, err := b.SendMessage("Sorry, queue is full. Try again later 🕙", b.chatID, nil)
-			log.Warn().Int("task_count", task_count).Msg("Queue is full.")
-			if err != nil {
-				log.Error().Err(err).Msg("Failed to send message.")
-				time.Sleep(10 * time.Second)
-			}
-			return b.handleLogin, true
-		}
-
-		x := 360 / 6 * data.Result.Dice.Value
-		y := 90 / 6 * data2.Result.Dice.Value
-
-		time.Sleep(5 * time.Second)
-
-		log.Info().Strs("user", []string{update.Message.From.FirstName, update.Message.From.LastName, update.Message.From.Username}).Ints("cords", []int{x, y}).Msg("Doing dice photo.")
-
-		task_count++
-		go b.AccessCamera(x, y)
-
-		_, err = b.SendMessage(fmt.Sprintf("%v, doing photo 🖼 on coordinates X: %v Y: %v, please wait 🕙", update.Message.From.FirstName, x, y), b.chatID, nil)
-		if err != nil {
-			log.Error().Err(err).Msg("Failed to send message.")
-			time.Sleep(10 * time.Second)
-		}
+// newGuestPass replaces the guest password with a random one valid for dur.
+func newGuestPass(dur time.Duration) {
+	guestPass.Lock()
+	guestPass.pass = fmt.Sprint(randsrc.Int())
+	guestPass.expiry = clk.Now().Add(dur)
+	guestPass.Unlock()
+}
 
-		return b.handleLogin, true
-	} else if update.Message.Text == "/eventcreate" {
-		if b.isGuest {
-			log.Warn().Strs("user", []string{update.Message.From.FirstName, update.Message.From.LastName, update.Message.From.Username}).Msg("Guest can not do that.")
-			if _, err := b.SendMessage(update.Message.From.FirstName+", you can not do that as guest [🛑]", b.chatID, nil); err != nil {
-				log.Error().Err(err).Msg("Failed to send message.")
-				time.Sleep(10 * time.Second)
-			}
-			return b.handleLogin, true
-		}
+// currentGuestPass returns the guest password and when it gets replaced.
+func currentGuestPass() (string, time.Time) {
+	guestPass.Lock()
+	defer guestPass.Unlock()
+	return guestPass.pass, guestPass.expiry
+}
 
-		if b.Event.Active {
-			_, err := b.SendMessage(fmt.Sprintf("%v, delete your existing event first (X: %v Y: %v %v:%v Sunset:%v) 🎉", update.Message.From.FirstName, b.Event.X, b.Event.Y, b.Event.Hour, b.Event.Minute, b.Event.Sunset), b.chatID, nil)
-			if err != nil {
-				log.Error().Err(err).Msg("Failed to send message.")
-				time.Sleep(10 * time.Second)
-			}
-			log.Warn().Strs("user", []string{update.Message.From.FirstName, update.Message.From.LastName, update.Message.From.Username}).Msg("User have event already.")
-			return b.handleLogin, true
-		}
+func (b *Bot) handleSunset(update *echotron.Update) stateFn {
+	if state, ok := b.checkCommands(update); ok {
+		return state
+	}
 
-		_, err := b.SendMessage(fmt.Sprintf("%v, event will send you photo 🖼 everyday at exact time, to create an event send information in format \"X Y Hours Minutes\" 😁", update.Message.From.FirstName), b.chatID, nil)
-		if err != nil {
-			log.Error().Err(err).Msg("Failed to send message.")
-			time.Sleep(10 * time.Second)
-		}
-		return b.handleEventCreate, true
-	} else if update.Message.Text == "/eventdelete" {
-		if !b.Event.Active {
-			_, err := b.SendMessage(fmt.Sprintf("%v, you have no existing event [🛑]", update.Message.From.FirstName), b.chatID, nil)
-			if err != nil {
-				log.Error().Err(err).Msg("Failed to send message.")
-				time.Sleep(10 * time.Second)
-			}
-			log.Warn().Strs("user", []string{update.Message.From.FirstName, update.Message.From.LastName, update.Message.From.Username}).Msg("User have no events.")
-			return b.handleLogin, true
-		}
+	return b.createSunset(update, b.promptArgs(update))
+}
 
-		_, err := b.SendMessage(fmt.Sprintf("%v, deleted your existing event (X: %v Y: %v %v:%v Sunset:%v) 🎉", update.Message.From.FirstName, b.Event.X, b.Event.Y, b.Event.Hour, b.Event.Minute, b.Event.Sunset), b.chatID, nil)
+func (b *Bot) createSunset(update *echotron.Update, args []string) stateFn {
+	condArgs, args := splitConditions(args)
+	var conds []condition
+	for _, a := range condArgs {
+		c, err := parseCondition(a)
 		if err != nil {
-			log.Error().Err(err).Msg("Failed to send message.")
-			time.Sleep(10 * time.Second)
-		}
-		log.Info().Strs("user", []string{update.Message.From.FirstName, update.Message.From.LastName, update.Message.From.Username}).Ints("cords", []int{b.Event.X, b.Event.Y}).Ints("time", []int{b.Event.Hour, b.Event.Minute}).Bool("sunset", b.Event.Sunset).Msg("Deleted event.")
-		b.Event.Active = false
-
-		return b.handleLogin, true
-	} else if update.Message.Text == "/eventsunset" {
-		if b.isGuest {
-			log.Warn().Strs("user", []string{update.Message.From.FirstName, update.Message.From.LastName, update.Message.From.Username}).Msg("Guest can not do that.")
-			if _, err := b.SendMessage(update.Message.From.FirstName+", you can not do that as guest [🛑]", b.chatID, nil); err != nil {
-				log.Error().Err(err).Msg("Failed to send message.")
-				time.Sleep(10 * time.Second)
-			}
-			return b.handleLogin, true
-		}
-
-		if b.Event.Active {
-			log.Warn().Strs("user", []string{update.Message.From.FirstName, update.Message.From.LastName, update.Message.From.Username}).Msg("User already have an event.")
-			if _, err := b.SendMessage(update.Message.From.FirstName+", please delete your existing event first 🎉", b.chatID, nil); err != nil {
-				log.Error().Err(err).Msg("Failed to send message.")
-				time.Sleep(10 * time.Second)
-			}
-			return b.handleLogin, true
-		}
-		if _, err := b.SendMessage("Enter X and Y coordinate to create sunset event 🌆", b.chatID, nil); err != nil {
-			log.Error().Err(err).Msg("Failed to send message.")
-			time.Sleep(10 * time.Second)
-		}
-		return b.handleSunset, true
-	} else if update.Message.Text == "/sunsettime" {
-		if _, err := b.SendMessage(update.Message.From.FirstName+", today you can see sunset at "+fmt.Sprint(hoursunset)+":"+fmt.Sprint(minutesunset), b.chatID, nil); err != nil {
-			log.Error().Err(err).Msg("Failed to send message.")
-			time.Sleep(10 * time.Second)
-		}
-		return b.handleLogin, true
-	} else if update.Message.Text == "/guestpass" {
-		if b.isGuest {
-			log.Warn().Strs("user", []string{update.Message.From.FirstName, update.Message.From.LastName, update.Message.From.Username}).Msg("Guest can not do that.")
-			if _, err := b.SendMessage(update.Message.From.FirstName+", you can not do that as guest [🛑]", b.chatID, nil); err != nil {
-				log.Error().Err(err).Msg("Failed to send message.")
-				time.Sleep(10 * time.Second)
-			}
-			return b.handleLogin, true
+			log.Warn().Err(err).Str("condition", a).Msg("Invalid weather condition.")
+			b.reply("condition_invalid", update.Message.From.FirstName, a)
+			return b.await(b.handleSunset)
 		}
-		if _, err := b.SendMessage(update.Message.From.FirstName+", guest password 🔐 for next 8 hours is "+guestpass, b.chatID, nil); err != nil {
-			log.Error().Err(err).Msg("Failed to send message.")
-			time.Sleep(10 * time.Second)
-		}
-		return b.handleLogin, true
-	}
-	return nil, false
-}
-
-func GenGuestPass(dur time.Duration) {
-	for range time.Tick(dur) {
-		guestpass = fmt.Sprint(randsrc.Int())
-		log.Info().Str("password", guestpass).Msg("generated new guest password.")
+		conds = append(conds, c)
 	}
-}
 
-func (b *Bot) handleSunset(update *echotron.Update) stateFn {
-	if state, ok := b.checkCommands(update); ok {
-		return state
+	cam, cords, ok := b.cameraArg(update, args, 2)
+	if !ok {
+		return b.await(b.handleSunset)
 	}
-	cords := strings.Fields(update.Message.Text)
 	if len(cords) != 2 {
 		log.Warn().Str("cords", update.Message.Text).Msg("Coordinates are not two numbers.")
-		if _, err := b.SendMessage(update.Message.From.FirstName+", please enter two coordinates 🕹", b.chatID, nil); err != nil {
-			log.Error().Err(err).Msg("Failed to send message.")
-			time.Sleep(10 * time.Second)
-		}
-		return b.handleSunset
+		b.reply("sunset_count", update.Message.From.FirstName)
+		return b.await(b.handleSunset)
 	}
 	x, err := strconv.Atoi(cords[0])
 	y, err2 := strconv.Atoi(cords[1])
 	if err2 != nil || err != nil {
 		log.Warn().Strs("cords", cords).Msg("X or Y is not a number.")
-		_, err := b.SendMessage(update.Message.From.FirstName+", please specify valid coordinates X Y 🕹 in degrees to create an event 📷", b.chatID, nil)
-		if err != nil {
-			log.Error().Err(err).Msg("Failed to send message.")
-			time.Sleep(10 * time.Second)
-		}
-		return b.handlePhoto
+		b.reply("sunset_invalid", update.Message.From.FirstName)
+		return b.await(b.handleSunset)
 	}
 
 	if x < 0 || x > 360 {
 		log.Warn().Int("x", x).Msg("X is greater than 360 or negative.")
-		_, err := b.SendMessage(update.Message.From.FirstName+", X coordinate should be greater than 0, but smaller than 360 [🛑]", b.chatID, nil)
-		if err != nil {
-			log.Error().Err(err).Msg("Failed to send message.")
-			time.Sleep(10 * time.Second)
-		}
-		return b.handlePhoto
+		b.reply("x_range", update.Message.From.FirstName)
+		return b.await(b.handleSunset)
 	} else if y < 0 || y > 90 {
 		log.Warn().Int("y", y).Msg("Y is greater than 90 or negative.")
-		_, err := b.SendMessage(update.Message.From.FirstName+", Y coordinate should be greater than 0, but smaller than 90 [🛑]", b.chatID, nil)
-		if err != nil {
-			log.Error().Err(err).Msg("Failed to send message.")
-			time.Sleep(10 * time.Second)
-		}
-		return b.handlePhoto
+		b.reply("y_range", update.Message.From.FirstName)
+		return b.await(b.handleSunset)
+	} else if cam.forbidden(x, y) {
+		b.forbiddenZone(update, cam, x, y)
+		return b.await(b.handleSunset)
 	}
 
-	if _, err := b.SendMessage("Created sunset 🌆 event at coordinates "+fmt.Sprint(x)+" "+fmt.Sprint(y), b.chatID, nil); err != nil {
+	text := b.tr("sunset_created", cam.Name, x, y)
+	if len(conds) > 0 {
+		text = b.tr("sunset_created_if", cam.Name, x, y, strings.Join(condArgs, " "))
+	}
+	if _, err := b.SendMessage(text, b.chatID, nil); err != nil {
 		log.Error().Err(err).Msg("Failed to send message.")
 		time.Sleep(10 * time.Second)
 		return b.handleLogin
 	}
-	log.Info().Strs("user", []string{update.Message.From.FirstName, update.Message.From.LastName, update.Message.From.Username}).Ints("cords", []int{x, y}).Msg("Created sunset event.")
-
-	b.Event = event{X: x, Y: y, Active: true, Sunset: true}
+	log.Info().Strs("user", []string{update.Message.From.FirstName, update.Message.From.LastName, update.Message.From.Username}).Str("camera", cam.Name).Ints("cords", []int{x, y}).Msg("Created sunset event.")
 
-	go b.runEvent()
+	addEvent(b.chatID, event{X: x, Y: y, Sunset: true, Owner: userName(update.Message.From), OwnerID: update.Message.From.ID, Camera: cam.Name, Conditions: conds})
 
 	return b.handleLogin
 }
@@ -406,77 +314,183 @@ func (b *Bot) handleLogin(update *echotron.Update) stateFn {
 	}
 
 	log.Info().Str("cmd", update.Message.Text).Msg("Unknown command.")
-	if _, err := b.SendMessage(update.Message.From.FirstName+", I dont understand command: "+update.Message.Text, b.chatID, nil); err != nil {
-		log.Error().Err(err).Msg("Failed to send message.")
-		time.Sleep(10 * time.Second)
-	}
+	b.reply("unknown_command", update.Message.From.FirstName, update.Message.Text)
 	return b.handleLogin
 }
 
 func (b *Bot) handleMessage(update *echotron.Update) stateFn {
-	if update.Message.Text == guestpass {
+	if pass, _ := currentGuestPass(); update.Message.Text == pass {
 		log.Info().Strs("user", []string{update.Message.From.FirstName, update.Message.From.LastName, update.Message.From.Username}).Msg("Logged in as guest.")
-		if _, err := b.SendMessage("Welcome back, "+update.Message.From.FirstName+", I am ready to work, please send me a \"/photo\" command to take a picture 🖼", b.chatID, nil); err != nil {
-			log.Error().Err(err).Msg("Failed to send message.")
-			time.Sleep(10 * time.Second)
-		}
-		b.isGuest = true
+		b.reply("welcome", update.Message.From.FirstName)
+		b.user.role = roleGuest
+		addGuest(b.user.id)
 		return b.handleLogin
 	} else if update.Message.Text == os.Getenv("PASSWORD") {
 		log.Info().Strs("user", []string{update.Message.From.FirstName, update.Message.From.LastName, update.Message.From.Username}).Msg("Logged in.")
-		_, err := b.SendMessage("Welcome back, "+update.Message.From.FirstName+", I am ready to work, please send me a \"/photo\" command to take a picture 🖼", b.chatID, nil)
-		if err != nil {
-			log.Error().Err(err).Msg("Failed to send message.")
-			time.Sleep(10 * time.Second)
-		}
+		b.user.role = roleAdmin
+		addAdminUser(b.user.id)
+		addAdmin(b.chatID)
+		go setAdminMenu(b.chatID)
+		b.reply("welcome", update.Message.From.FirstName)
 		return b.handleLogin
 	} else {
-		_, err := b.SendMessage("Hello "+update.Message.From.FirstName+" 🖐,I am ready to take some photos 📷. Please send me your password😉", b.chatID, nil)
-		if err != nil {
-			log.Error().Err(err).Msg("Failed to send message.")
-			time.Sleep(10 * time.Second)
-		}
+		b.reply("hello", update.Message.From.FirstName)
 	}
 	return b.handleMessage
 }
 
-func (b *Bot) AccessCamera(x, y int) {
-	b.mu.Lock()
-	defer b.mu.Unlock()
+func userName(user *echotron.User) string {
+	name := strings.TrimSpace(user.FirstName + " " + user.LastName)
+	if user.Username != "" {
+		name += " (@" + user.Username + ")"
+	}
+	return name
+}
 
-	defer func() { task_count -= 1 }()
+// defaultCamera returns the camera chosen for the chat with /camera, it is
+// kept in the state file so the choice outlives the session.
+func (b *Bot) defaultCamera() *camera {
+	var name string
+	viewStore(func(p *persistent) {
+		name = p.Cameras[b.chatID]
+	})
+	if cam, ok := cameras[name]; ok {
+		return cam
+	}
+	return defaultCamera()
+}
 
-	cmd := exec.Command("./motor_driver.bin", fmt.Sprint(x), fmt.Sprint(y), "False", fmt.Sprint(CameraPosX), "3", "wget -N -P . http://127.0.0.1:8080/photoaf.jpg")
-	err := cmd.Run()
-	if err != nil {
-		b.SendMessage("Cant access motor_driver [🛑], try again later 🕑", b.chatID, nil)
-		log.Error().Err(err).Msg("Failed to access motor_driver.")
+func (b *Bot) unknownCamera(update *echotron.Update, name string) {
+	log.Warn().Str("camera", name).Msg("Unknown camera.")
+	b.reply("camera_unknown", update.Message.From.FirstName, name, strings.Join(cameraNames, ", "))
+}
+
+// cameraArg splits optional camera name off the front of args that otherwise
+// hold n values. It reports false if the named camera does not exist.
+func (b *Bot) cameraArg(update *echotron.Update, args []string, n int) (*camera, []string, bool) {
+	if len(args) != n+1 {
+		return b.defaultCamera(), args, true
+	}
+	cam, ok := findCamera(args[0])
+	if !ok {
+		b.unknownCamera(update, args[0])
+		return nil, nil, false
+	}
+	return cam, args[1:], true
+}
+
+// needPrompt reports whether command arguments are missing, so the command
+// has to ask for them. A lone camera name is kept for the prompt answer.
+func (b *Bot) needPrompt(args []string) bool {
+	b.user.partial = nil
+	if len(args) == 0 {
+		return true
+	}
+	if len(args) == 1 {
+		if _, err := strconv.Atoi(args[0]); err != nil {
+			if _, ok := findCamera(args[0]); ok {
+				b.user.partial = args
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// promptArgs returns the prompt answer prefixed with arguments of the command
+// that asked for it.
+func (b *Bot) promptArgs(update *echotron.Update) []string {
+	return append(append([]string{}, b.user.partial...), strings.Fields(update.Message.Text)...)
+}
+
+func (b *Bot) AccessCamera(cam *camera, j *job) {
+	x, y, requester := j.X, j.Y, j.Requester
+
+	// Zones may be added after the job was queued or the event was created.
+	if cam.forbidden(x, y) {
+		b.SendMessage(b.tr("zone_dropped", cam.Name, x, y), b.chatID, nil)
+		log.Warn().Str("camera", cam.Name).Ints("cords", []int{x, y}).Msg("Coordinates are in no-go zone, dropping job.")
+		return
+	}
+	// Every allowed job reports its result to the breaker, which ends the
+	// half-open trial.
+	if !cam.breaker.allow() {
+		b.SendMessage(b.tr("camera_unavailable"), b.chatID, nil)
+		log.Warn().Str("camera", cam.Name).Ints("cords", []int{x, y}).Msg("Camera breaker is open, dropping job.")
+		return
+	}
+
+	caption := fmt.Sprintf("X: %v Y: %v", x, y)
+	if len(cameraNames) > 1 {
+		caption = cam.Name + " " + caption
+	}
+	if j.Clip > 0 {
+		b.sendClip(cam, j, caption)
 		return
 	}
 
-	CameraPosX = x
-	opts := &echotron.PhotoOptions{Caption: fmt.Sprintf("X: %v Y: %v", x, y)}
-	f, err := os.Open("photoaf.jpg")
+	opts := &echotron.PhotoOptions{Caption: caption}
+	data, err := cam.captureWithRecovery(captures, x, y)
+	if err != nil && captures.Err() != nil {
+		handBack(cam, j)
+		log.Warn().Str("camera", cam.Name).Ints("cords", []int{x, y}).Int("job", j.ID).Msg("Shutting down, persisting cancelled photo.")
+		return
+	}
 	if err != nil {
-		b.SendMessage("Cant get photo [🛑], try again later 🕙", b.chatID, nil)
-		phoneinit := exec.Command("./phone_init.sh")
-		phoneinit.Run()
-		log.Error().Err(err).Msg("Error accured: initialized phone.")
+		cam.failed(err)
+	}
+	if err != nil && classifyFailure(err) == failureMotor {
+		b.SendMessage(b.tr("motor_failed"), b.chatID, nil)
+		log.Error().Err(err).Msg("Failed to access motor_driver.")
+		return
+	} else if err != nil {
+		b.SendMessage(b.tr("photo_failed"), b.chatID, nil)
+		log.Error().Err(err).Msg("Failed to get photo after reinitializing phone.")
 		return
 	}
-	data, err := io.ReadAll(f)
+
+	// Never send the photo if masking failed, it would expose private areas.
+	data, err = applyMasks(data, cam.Masks, x, y)
 	if err != nil {
-		b.SendMessage("Cant read image file [🛑], try again later 🕘", b.chatID, nil)
-		log.Error().Err(err).Msg("Failed to read image file.")
+		cam.failed(err)
+		b.SendMessage(b.tr("process_failed"), b.chatID, nil)
+		log.Error().Err(err).Str("camera", cam.Name).Msg("Failed to apply privacy masks.")
 		return
 	}
 
-	_, err = b.SendPhoto(echotron.NewInputFileBytes("photoaf.jpg", data), b.chatID, opts)
+	now := clk.Now()
+	if burned, err := drawOverlay(data, x, y, cam.bearing(x), now); err != nil {
+		log.Error().Err(err).Msg("Failed to draw overlay.")
+	} else {
+		data = burned
+	}
+
+	meta := exifMeta{
+		Time:      now,
+		Lat:       cam.Lat,
+		Lng:       cam.Lng,
+		Direction: cam.bearing(x),
+		Comment:   fmt.Sprintf("Y: %v requested by %v", y, requester),
+	}
+	if tagged, err := embedExif(data, meta); err != nil {
+		log.Error().Err(err).Msg("Failed to embed EXIF.")
+	} else {
+		data = tagged
+	}
+
+	name := fmt.Sprintf("photo_%v.jpg", meta.Time.Format("20060102_150405"))
+	if sendDocument {
+		_, err = b.SendDocument(echotron.NewInputFileBytes(name, data), b.chatID, &echotron.DocumentOptions{Caption: opts.Caption})
+	} else {
+		_, err = b.SendPhoto(echotron.NewInputFileBytes(name, data), b.chatID, opts)
+	}
 	if err != nil {
-		b.SendMessage("Cant send photo [🛑], try again later 🕞", b.chatID, nil)
+		cam.failed(err)
+		b.SendMessage(b.tr("send_failed"), b.chatID, nil)
 		log.Error().Err(err).Msg("Cant send photo.")
+		return
 	}
-	os.Remove("photoaf.jpg")
+	cam.captured()
 }
 
 func (b *Bot) handlePhoto(update *echotron.Update) stateFn {
@@ -485,72 +499,74 @@ func (b *Bot) handlePhoto(update *echotron.Update) stateFn {
 		return state
 	}
 
-	cords := strings.Fields(update.Message.Text)
+	return b.takePhoto(update, b.promptArgs(update))
+}
+
+// parseCoord parses absolute coordinate or, with sign prefix, coordinate
+// relative to cur.
+func parseCoord(s string, cur int) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, err
+	}
+	if strings.HasPrefix(s, "+") || strings.HasPrefix(s, "-") {
+		return cur + n, nil
+	}
+	return n, nil
+}
+
+// takePhoto queues a photo from "[camera] X Y" arguments, X and Y prefixed
+// with + or - are relative to the current camera position.
+func (b *Bot) takePhoto(update *echotron.Update, args []string) stateFn {
+	cam, cords, ok := b.cameraArg(update, args, 2)
+	if !ok {
+		return b.await(b.handlePhoto)
+	}
 	if len(cords) != 2 {
 		log.Warn().Str("cords", update.Message.Text).Msg("Coordinates are not two numbers.")
-		_, err := b.SendMessage(fmt.Sprintf("%v, please specify coordinates X Y 🕹 in degrees to turn camera 📷", update.Message.From.FirstName), b.chatID, nil)
-		if err != nil {
-			log.Error().Err(err).Msg("Failed to send message.")
-			time.Sleep(10 * time.Second)
-		}
-		return b.handlePhoto
+		b.reply("photo_invalid", update.Message.From.FirstName)
+		return b.await(b.handlePhoto)
 	}
-	x, err := strconv.Atoi(cords[0])
-	y, err2 := strconv.Atoi(cords[1])
+	pos := cam.position()
+	x, err := parseCoord(cords[0], pos.X)
+	y, err2 := parseCoord(cords[1], pos.Y)
 	if err2 != nil || err != nil {
 		log.Warn().Strs("cords", cords).Msg("X or Y is not a number.")
-		_, err := b.SendMessage(fmt.Sprintf("%v, please specify coordinates X Y 🕹 in degrees to turn camera 📷", update.Message.From.FirstName), b.chatID, nil)
-		if err != nil {
-			log.Error().Err(err).Msg("Failed to send message.")
-			time.Sleep(10 * time.Second)
-		}
-		return b.handlePhoto
+		b.reply("photo_invalid", update.Message.From.FirstName)
+		return b.await(b.handlePhoto)
 	}
 
 	if x < 0 || x > 360 {
 		log.Warn().Int("x", x).Msg("X is greater than 360 or negative.")
-		_, err := b.SendMessage(fmt.Sprintf("%v, X coordinate should be greater than 0, but smaller than 360 [🛑]", update.Message.From.FirstName), b.chatID, nil)
-		if err != nil {
-			log.Error().Err(err).Msg("Failed to send message.")
-			time.Sleep(10 * time.Second)
-		}
-		return b.handlePhoto
+		b.reply("x_range", update.Message.From.FirstName)
+		return b.await(b.handlePhoto)
 	} else if y < 0 || y > 90 {
 		log.Warn().Int("y", y).Msg("Y is greater than 90 or negative.")
-		_, err := b.SendMessage(fmt.Sprintf("%v, Y coordinate should be greater than 0, but smaller than 90 [🛑]", update.Message.From.FirstName), b.chatID, nil)
-		if err != nil {
-			log.Error().Err(err).Msg("Failed to send message.")
-			time.Sleep(10 * time.Second)
-		}
-		return b.handlePhoto
-	} else if task_count+1 > queue_cap {
-		log.Warn().Int("task_count", task_count).Msg("Queue is full.")
-		_, err := b.SendMessage("Sorry, queue is full. Try again later 🕙", b.chatID, nil)
-		if err != nil {
-			log.Error().Err(err).Msg("Failed to send message.")
-			time.Sleep(10 * time.Second)
-		}
+		b.reply("y_range", update.Message.From.FirstName)
+		return b.await(b.handlePhoto)
+	} else if cam.forbidden(x, y) {
+		b.forbiddenZone(update, cam, x, y)
+		return b.await(b.handlePhoto)
+	} else if !cam.breaker.available() {
+		log.Warn().Str("camera", cam.Name).Msg("Camera breaker is open.")
+		b.reply("camera_unavailable")
 		return b.handleLogin
 	}
 
-	log.Info().Strs("user", []string{update.Message.From.FirstName, update.Message.From.LastName, update.Message.From.Username}).Ints("cords", []int{x, y}).Msg("Doing photo.")
-
-	task_count++
-	go b.AccessCamera(x, y)
-
-	_, err = b.SendMessage(fmt.Sprintf("%v, added your request to the queue, please wait 🕙", update.Message.From.FirstName), b.chatID, nil)
-	if err != nil {
-		log.Error().Err(err).Msg("Failed to send message.")
-		time.Sleep(10 * time.Second)
+	j, ok := cam.enqueue(b, update.Message.From.ID, userName(update.Message.From), x, y, rolePriority(b.role()))
+	if !ok {
+		log.Warn().Str("camera", cam.Name).Int("task_count", cam.queueLen()).Msg("Queue is full.")
+		b.reply("queue_full")
+		return b.handleLogin
 	}
+	log.Info().Strs("user", []string{update.Message.From.FirstName, update.Message.From.LastName, update.Message.From.Username}).Str("camera", cam.Name).Ints("cords", []int{x, y}).Int("job", j.ID).Msg("Doing photo.")
+
+	b.replyQueued(j, "photo_queued", update.Message.From.FirstName, j.ID)
 
 	return b.handleLogin
 }
 
 func LogsControl() {
-	var file *os.File
-	var err error
-
 	for {
 		timenow := time.Now()
 		if weekday != timenow.Weekday().String() {
@@ -558,14 +574,17 @@ func LogsControl() {
 
 			log.Info().Str("weekday", weekday).Msg("New day started, closing logs file.")
 
-			file.Close()
-
-			file, err = os.Create(fmt.Sprintf("logs/%v_%v_%v.txt", timenow.Day(), timenow.Month(), timenow.Year()))
+			file, err := os.Create(fmt.Sprintf("logs/%v_%v_%v.txt", timenow.Day(), timenow.Month(), timenow.Year()))
 			if err != nil {
 				log.Fatal().Err(err).Msg("Failed to create new logs file.")
 			}
 
-			log.Logger = log.Output(file)
+			logs.Lock()
+			if logs.file != nil {
+				logs.file.Close()
+			}
+			logs.file = file
+			logs.Unlock()
 
 			dir, err := os.ReadDir("logs/")
 			if err != nil {
@@ -595,88 +614,185 @@ func LogsControl() {
 					continue
 				}
 			}
+		}
 
-			req, err := http.NewRequest("GET", "https://api.sunrise-sunset.org/json?lat=56.968&lng=23.77038", nil)
-			if err != nil {
-				log.Error().Err(err).Msg("Failed to create http request.")
-				continue
+		// Sunset is fetched once a day, retrying until it succeeds.
+		if sunsetDay != weekday {
+			if err := fetchSunset(); err != nil {
+				raiseAlert("sunset", "alert_sunset", err)
+			} else {
+				sunsetDay = weekday
+				resolveAlert("sunset")
 			}
+		}
+		time.Sleep(time.Minute * 5)
+	}
+}
 
-			resp, err := http.DefaultClient.Do(req)
-			if err != nil {
-				log.Error().Err(err).Msg("Failed to complete request.")
-				continue
-			}
+// fetchSunset updates the sunset time from api.sunrise-sunset.org.
+func fetchSunset() error {
+	req, err := http.NewRequest("GET", fmt.Sprintf("https://api.sunrise-sunset.org/json?lat=%v&lng=%v", cameraLat, cameraLng), nil)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to create http request.")
+		return err
+	}
 
-			data, err := io.ReadAll(resp.Body)
-			if err != nil {
-				log.Error().Err(err).Msg("Failed to read response body")
-				continue
-			}
-			resp.Body.Close()
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to complete request.")
+		return err
+	}
+	defer resp.Body.Close()
 
-			var jsondata map[string]interface{}
-			err = json.Unmarshal(data, &jsondata)
-			if err != nil {
-				log.Error().Err(err).Msg("Failed to unmarshal json data.")
-				continue
-			}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to read response body")
+		return err
+	}
 
-			var results map[string]interface{} = jsondata["results"].(map[string]interface{})
-			var sunset string = results["sunset"].(string)
-			parseSunsetTime(sunset)
-		}
-		time.Sleep(time.Minute * 5)
+	var jsondata struct {
+		Results struct {
+			Sunset string `json:"sunset"`
+		} `json:"results"`
 	}
+	if err := json.Unmarshal(data, &jsondata); err != nil {
+		log.Error().Err(err).Msg("Failed to unmarshal json data.")
+		return err
+	}
+	return parseSunsetTime(jsondata.Results.Sunset)
 }
 
-func parseSunsetTime(sunset string) {
+func parseSunsetTime(sunset string) error {
 	data := strings.Split(sunset, ":")
+	if len(data) < 2 {
+		log.Error().Str("sunset", sunset).Msg("Failed to parse sunset time.")
+		return fmt.Errorf("invalid sunset time %q", sunset)
+	}
 	hours, err := strconv.Atoi(data[0])
 	if err != nil {
 		log.Error().Err(err).Str("hours", data[0]).Msg("Failed to parse sunset hours.")
-		return
+		return err
 	}
 	minutes, err := strconv.Atoi(data[1])
 	if err != nil {
 		log.Error().Err(err).Str("minutes", data[1]).Msg("Failed to parse sunset minutes.")
-		return
+		return err
 	}
 
 	log.Info().Ints("time", []int{hours + 15, minutes}).Msg("Parsed sunset time.")
-	hoursunset = hours + 15
-	minutesunset = minutes
+	setSunsetTime(hours+15, minutes)
+	return nil
+}
+
+// sunsetTime returns hours and minutes of today's sunset.
+func sunsetTime() (int, int) {
+	sunsetAt.Lock()
+	defer sunsetAt.Unlock()
+	return sunsetAt.hour, sunsetAt.minute
+}
+
+// setSunsetTime updates the sunset time and moves sunset events to it. The
+// time is saved, so after restart events use it until sunset is fetched.
+func setSunsetTime(hour, minute int) {
+	sunsetAt.Lock()
+	sunsetAt.hour, sunsetAt.minute = hour, minute
+	sunsetAt.Unlock()
+	updateStore(func(p *persistent) {
+		p.Sunset = &dayTime{Hour: hour, Minute: minute}
+	})
+	rescheduleSunset()
+}
+
+// loadSunset restores the sunset time fetched by the last run.
+func loadSunset() {
+	viewStore(func(p *persistent) {
+		if p.Sunset != nil {
+			sunsetAt.Lock()
+			sunsetAt.hour, sunsetAt.minute = p.Sunset.Hour, p.Sunset.Minute
+			sunsetAt.Unlock()
+		}
+	})
+}
+
+// loadConfig reads configuration from env and files and loads saved state.
+func loadConfig() {
+	randsrc = rand.New(rand.NewSource(time.Now().Unix()))
+
+	cameraLat = envFloat("CAMERA_LAT", 56.968)
+	cameraLng = envFloat("CAMERA_LNG", 23.77038)
+	sendDocument = envBool("SEND_DOCUMENT", false)
+	promptTimeout = envDuration("PROMPT_TIMEOUT", 5*time.Minute)
+	queueReserved = envInt("QUEUE_RESERVED", 1)
+	eventCatchup = envDuration("EVENT_CATCHUP", 30*time.Minute)
+
+	loadOverlayConfig()
+	loadFetchConfig()
+	loadStore()
+	loadSunset()
+	loadCameras()
+	loadAdmins()
+	loadAlerts()
+	loadWeather()
+	loadClipConfig()
+	registerCommands()
+
+	newGuestPass(time.Hour * 8)
 }
 
-func init() {
+// initCameras restores saved camera positions or homes the cameras and
+// queues photos left from the last shutdown.
+func initCameras() {
+	for _, name := range cameraNames {
+		if cameras[name].restorePosition() {
+			pos := cameras[name].position()
+			log.Info().Str("camera", name).Ints("cords", []int{pos.X, pos.Y}).Msg("Restored camera position.")
+			continue
+		}
+		if err := cameras[name].home(); err != nil {
+			log.Fatal().Err(err).Str("camera", name).Msg("Failed to initialize camera.")
+		}
+		log.Info().Str("camera", name).Msg("Initialized camera to X: 0 coordinate.")
+	}
+
+	restorePending()
+}
+
+func main() {
 	err := godotenv.Load()
 	if err != nil {
 		log.Fatal().Err(err).Msg("Cant load env variables.")
 	}
-	randsrc = rand.New(rand.NewSource(time.Now().Unix()))
+	loadConfig()
 
+	log.Logger = log.Output(&logs)
 	go LogsControl()
 
-	cmd := exec.Command("./motor_driver.bin", "0", "0", "True", "0", "3", "")
-	err = cmd.Run()
-	if err != nil {
-		log.Fatal().Err(err).Msg("Failed to initialize camera.")
-	}
-
-	log.Info().Msg("Initialized camera to X: 0 coordinate.")
+	initCameras()
+	loadEvents()
 
-	guestpass = fmt.Sprint(randsrc.Int())
-}
+	// Photos are kept in memory now, drop the file left by the old wget based capture.
+	os.Remove("photoaf.jpg")
 
-func main() {
+	started = clk.Now()
 	go GenGuestPass(time.Hour * 8)
+	go runScheduler()
+
+	api := echotron.NewAPI(os.Getenv("TOKEN"))
+	me, err := api.GetMe()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get bot username.")
+	} else {
+		botUsername = me.Result.Username
+	}
 
-	dsp = echotron.NewDispatcher(os.Getenv("TOKEN"), newBot)
+	setCommandMenu()
 
-	log.Info().Msg("Created bot dispacther.")
+	go handleSignals()
 
 	for {
-		log.Error().Err(dsp.Poll()).Msg("Poll error accured.")
+		err := poll(api)
+		log.Error().Err(err).Msg("Poll error accured.")
+		raiseAlert("poll", "alert_poll", err)
 
 		time.Sleep(5 * time.Second)
 	}
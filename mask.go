@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+)
+
+// mask hides a polygon of the frame while camera is within X and Y ranges.
+// Polygon points are fractions of frame width and height, so masks do not
+// depend on the photo resolution. Mode is "black" or "pixelate".
+type mask struct {
+	X       [2]int       `json:"x"`
+	Y       [2]int       `json:"y"`
+	Mode    string       `json:"mode"`
+	Polygon [][2]float64 `json:"polygon"`
+}
+
+func (m mask) applies(x, y int) bool {
+	return x >= m.X[0] && x <= m.X[1] && y >= m.Y[0] && y <= m.Y[1]
+}
+
+// applyMasks hides masked regions of JPEG image taken at x, y.
+func applyMasks(data []byte, masks []mask, x, y int) ([]byte, error) {
+	var active []mask
+	for _, m := range masks {
+		if m.applies(x, y) && len(m.Polygon) >= 3 {
+			active = append(active, m)
+		}
+	}
+	if len(active) == 0 {
+		return data, nil
+	}
+
+	src, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	img := image.NewRGBA(src.Bounds())
+	draw.Draw(img, img.Bounds(), src, src.Bounds().Min, draw.Src)
+
+	for _, m := range active {
+		poly := make([]image.Point, len(m.Polygon))
+		for i, p := range m.Polygon {
+			poly[i] = image.Pt(
+				img.Rect.Min.X+int(p[0]*float64(img.Rect.Dx())),
+				img.Rect.Min.Y+int(p[1]*float64(img.Rect.Dy())),
+			)
+		}
+		if m.Mode == "pixelate" {
+			pixelate(img, poly)
+		} else {
+			fillPolygon(img, poly, func(int, int) color.RGBA { return color.RGBA{0, 0, 0, 255} })
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 90}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func polygonBounds(poly []image.Point) image.Rectangle {
+	r := image.Rectangle{Min: poly[0], Max: poly[0]}
+	for _, p := range poly[1:] {
+		r = r.Union(image.Rectangle{Min: p, Max: p.Add(image.Pt(1, 1))})
+	}
+	return r
+}
+
+// insidePolygon is the even-odd rule test for pixel center x, y.
+func insidePolygon(poly []image.Point, x, y int) bool {
+	px, py := float64(x)+0.5, float64(y)+0.5
+	inside := false
+	for i, j := 0, len(poly)-1; i < len(poly); j, i = i, i+1 {
+		xi, yi := float64(poly[i].X), float64(poly[i].Y)
+		xj, yj := float64(poly[j].X), float64(poly[j].Y)
+		if (yi > py) != (yj > py) && px < (xj-xi)*(py-yi)/(yj-yi)+xi {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+func fillPolygon(img *image.RGBA, poly []image.Point, fill func(x, y int) color.RGBA) {
+	r := polygonBounds(poly).Intersect(img.Rect)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			if insidePolygon(poly, x, y) {
+				img.SetRGBA(x, y, fill(x, y))
+			}
+		}
+	}
+}
+
+// pixelate replaces the polygon with big blocks of averaged colors.
+func pixelate(img *image.RGBA, poly []image.Point) {
+	block := img.Rect.Dx() / 40
+	if block < 8 {
+		block = 8
+	}
+
+	r := polygonBounds(poly).Intersect(img.Rect)
+	averages := make(map[image.Point]color.RGBA)
+	for by := r.Min.Y; by < r.Max.Y; by += block {
+		for bx := r.Min.X; bx < r.Max.X; bx += block {
+			cell := image.Rect(bx, by, bx+block, by+block).Intersect(r)
+			var sr, sg, sb, n int
+			for y := cell.Min.Y; y < cell.Max.Y; y++ {
+				for x := cell.Min.X; x < cell.Max.X; x++ {
+					c := img.RGBAAt(x, y)
+					sr, sg, sb, n = sr+int(c.R), sg+int(c.G), sb+int(c.B), n+1
+				}
+			}
+			if n > 0 {
+				averages[image.Pt(bx, by)] = color.RGBA{uint8(sr / n), uint8(sg / n), uint8(sb / n), 255}
+			}
+		}
+	}
+
+	fillPolygon(img, poly, func(x, y int) color.RGBA {
+		return averages[image.Pt(r.Min.X+(x-r.Min.X)/block*block, r.Min.Y+(y-r.Min.Y)/block*block)]
+	})
+}
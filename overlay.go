@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	_ "image/png"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+type overlayConfig struct {
+	Time      bool
+	Coords    bool
+	Compass   bool
+	Watermark string
+	Logo      image.Image
+	Corner    string
+}
+
+var overlay overlayConfig
+
+var compassPoints = []string{"N", "NE", "E", "SE", "S", "SW", "W", "NW"}
+
+// loadOverlayConfig reads overlay settings from env variables:
+// OVERLAY is a comma separated list of "time", "coords" and "compass",
+// OVERLAY_WATERMARK is a text, OVERLAY_LOGO a path to PNG or JPEG image
+// and OVERLAY_CORNER is one of "top-left", "top-right", "bottom-left", "bottom-right".
+func loadOverlayConfig() {
+	overlay = overlayConfig{Corner: "bottom-right"}
+
+	for _, v := range strings.Split(os.Getenv("OVERLAY"), ",") {
+		switch strings.TrimSpace(v) {
+		case "time":
+			overlay.Time = true
+		case "coords":
+			overlay.Coords = true
+		case "compass":
+			overlay.Compass = true
+		case "":
+		default:
+			log.Warn().Str("overlay", v).Msg("Unknown overlay.")
+		}
+	}
+
+	overlay.Watermark = os.Getenv("OVERLAY_WATERMARK")
+	if corner := os.Getenv("OVERLAY_CORNER"); corner != "" {
+		overlay.Corner = corner
+	}
+
+	if path := os.Getenv("OVERLAY_LOGO"); path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			log.Error().Err(err).Str("path", path).Msg("Failed to open logo.")
+			return
+		}
+		defer f.Close()
+
+		overlay.Logo, _, err = image.Decode(f)
+		if err != nil {
+			log.Error().Err(err).Str("path", path).Msg("Failed to decode logo.")
+		}
+	}
+}
+
+func (o overlayConfig) enabled() bool {
+	return o.Time || o.Coords || o.Compass || o.Watermark != "" || o.Logo != nil
+}
+
+func compassPoint(deg int) string {
+	return compassPoints[((deg*2+45)/90)%len(compassPoints)]
+}
+
+// drawOverlay burns enabled overlays into JPEG image and returns the new JPEG.
+// deg is the compass bearing the camera looks at.
+func drawOverlay(data []byte, x, y, deg int, t time.Time) ([]byte, error) {
+	if !overlay.enabled() {
+		return data, nil
+	}
+
+	src, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	img := image.NewRGBA(src.Bounds())
+	draw.Draw(img, img.Bounds(), src, src.Bounds().Min, draw.Src)
+
+	scale := img.Bounds().Dx() / 400
+	if scale < 2 {
+		scale = 2
+	}
+
+	var lines []string
+	if overlay.Time {
+		lines = append(lines, t.Format("2006-01-02 15:04:05"))
+	}
+	if overlay.Coords {
+		lines = append(lines, fmt.Sprintf("X: %v Y: %v", x, y))
+	}
+	if overlay.Compass {
+		lines = append(lines, fmt.Sprintf("%v° %v", deg, compassPoint(deg)))
+	}
+
+	infoCorner := "bottom-left"
+	if overlay.Corner == "bottom-left" {
+		infoCorner = "top-left"
+	}
+	drawTextBlock(img, lines, infoCorner, scale)
+
+	if overlay.Logo != nil {
+		drawLogo(img, overlay.Logo, overlay.Corner, scale)
+	} else if overlay.Watermark != "" {
+		drawTextBlock(img, []string{overlay.Watermark}, overlay.Corner, scale)
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 90}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// cornerRect places rectangle of given size into the corner of bounds.
+func cornerRect(bounds image.Rectangle, w, h, margin int, corner string) image.Rectangle {
+	p := image.Pt(bounds.Min.X+margin, bounds.Min.Y+margin)
+	if strings.HasSuffix(corner, "right") {
+		p.X = bounds.Max.X - margin - w
+	}
+	if strings.HasPrefix(corner, "bottom") {
+		p.Y = bounds.Max.Y - margin - h
+	}
+	return image.Rect(p.X, p.Y, p.X+w, p.Y+h)
+}
+
+func drawTextBlock(img draw.Image, lines []string, corner string, scale int) {
+	if len(lines) == 0 {
+		return
+	}
+
+	pad := 2 * scale
+	lineh := (glyphHeight + 2) * scale
+	width := 0
+	for _, line := range lines {
+		if w := len([]rune(line)) * (glyphWidth + 1) * scale; w > width {
+			width = w
+		}
+	}
+
+	rect := cornerRect(img.Bounds(), width+2*pad, len(lines)*lineh+2*pad, 4*scale, corner)
+	draw.Draw(img, rect, image.NewUniform(color.RGBA{0, 0, 0, 140}), image.Point{}, draw.Over)
+
+	for i, line := range lines {
+		drawText(img, line, rect.Min.X+pad, rect.Min.Y+pad+i*lineh+scale, scale, color.White)
+	}
+}
+
+func drawText(img draw.Image, text string, x, y, scale int, c color.Color) {
+	for _, r := range strings.ToUpper(text) {
+		glyph, ok := glyphs[r]
+		if !ok {
+			glyph = glyphs['?']
+		}
+		for row := 0; row < glyphHeight; row++ {
+			for col := 0; col < glyphWidth; col++ {
+				if glyph[row]&(1<<(glyphWidth-1-col)) == 0 {
+					continue
+				}
+				px := image.Rect(x+col*scale, y+row*scale, x+(col+1)*scale, y+(row+1)*scale)
+				draw.Draw(img, px, image.NewUniform(c), image.Point{}, draw.Src)
+			}
+		}
+		x += (glyphWidth + 1) * scale
+	}
+}
+
+func drawLogo(img draw.Image, logo image.Image, corner string, scale int) {
+	b := logo.Bounds()
+	rect := cornerRect(img.Bounds(), b.Dx(), b.Dy(), 4*scale, corner)
+	draw.Draw(img, rect, logo, b.Min, draw.Over)
+}
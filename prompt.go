@@ -0,0 +1,72 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NicoNex/echotron/v3"
+	"github.com/rs/zerolog/log"
+)
+
+// promptTimeout is how long a prompt waits for an answer before the bot
+// returns to handleLogin.
+var promptTimeout time.Duration
+
+// await keeps the member in prompt state next and starts the prompt timeout.
+func (b *Bot) await(next stateFn) stateFn {
+	m := b.user
+	m.promptGen++
+	gen := m.promptGen
+	m.pending = true
+	m.promptTimer = clk.AfterFunc(promptTimeout, func() {
+		b.expirePrompt(m, gen)
+	})
+	return next
+}
+
+// stopPrompt stops the timeout of the current prompt and returns generation
+// which is left unchanged unless await is called again.
+func (b *Bot) stopPrompt() int {
+	m := b.user
+	if m.promptTimer != nil {
+		m.promptTimer.Stop()
+		m.promptTimer = nil
+	}
+	m.promptGen++
+	return m.promptGen
+}
+
+func (b *Bot) expirePrompt(m *member, gen int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// User answered or started something else in the meantime.
+	if gen != m.promptGen || !m.pending {
+		return
+	}
+	log.Info().Int64("chat", b.chatID).Int64("user", m.id).Msg("Prompt expired.")
+	m.pending = false
+	m.partial = nil
+	m.state = b.handleLogin
+	b.reply("prompt_expired", m.name)
+}
+
+func (b *Bot) cmdCancel(update *echotron.Update, args []string) stateFn {
+	if len(args) > 0 {
+		// The argument schema makes args[0] a job ID.
+		id, _ := strconv.Atoi(strings.TrimPrefix(args[0], "#"))
+		b.reply(cancelJob(id, update.Message.From.ID), id)
+		return b.handleLogin
+	}
+	if !b.user.pending {
+		b.reply("cancel_none", update.Message.From.FirstName)
+		return b.handleLogin
+	}
+
+	log.Info().Strs("user", []string{update.Message.From.FirstName, update.Message.From.LastName, update.Message.From.Username}).Msg("Cancelled prompt.")
+	b.user.pending = false
+	b.user.partial = nil
+	b.reply("cancelled", update.Message.From.FirstName)
+	return b.handleLogin
+}
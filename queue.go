@@ -0,0 +1,295 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/NicoNex/echotron/v3"
+	"github.com/rs/zerolog/log"
+)
+
+// priority orders jobs in a camera queue, higher runs first.
+type priority int
+
+const (
+	prioGuest priority = iota
+	prioMember
+	prioAdmin
+	prioEvent
+)
+
+func rolePriority(r role) priority {
+	switch r {
+	case roleAdmin:
+		return prioAdmin
+	case roleMember:
+		return prioMember
+	}
+	return prioGuest
+}
+
+// queueReserved is how many of queue_cap slots only admins may take.
+// Scheduled events are never limited by queue_cap.
+var queueReserved int
+
+// job is a capture waiting in a camera queue or being taken.
+type job struct {
+	ID        int
+	Requester string
+	// User is who may cancel the job, zero for scheduled events.
+	User     int64
+	Chat     int64
+	X        int
+	Y        int
+	Priority priority
+	// Clip is the length of a video clip in seconds, zero for photos.
+	Clip    int
+	Added   time.Time
+	Started bool
+
+	bot *Bot
+}
+
+var jobSeq int64
+
+// hasRoom reports whether a job with priority p fits into the queue, c.smu
+// must be held.
+func (c *camera) hasRoom(p priority) bool {
+	switch {
+	case p == prioEvent:
+		return true
+	case p == prioAdmin:
+		return c.userJobs() < queue_cap
+	default:
+		return c.userJobs() < queue_cap-queueReserved
+	}
+}
+
+// full reports whether a job with priority p would be rejected.
+func (c *camera) full(p priority) bool {
+	c.smu.Lock()
+	defer c.smu.Unlock()
+	return !c.hasRoom(p)
+}
+
+// userJobs counts jobs requested by users, c.smu must be held.
+func (c *camera) userJobs() int {
+	n := 0
+	for _, j := range c.jobs {
+		if j.Priority != prioEvent {
+			n++
+		}
+	}
+	return n
+}
+
+// enqueue adds a capture to the camera queue if there is room for priority p.
+func (c *camera) enqueue(b *Bot, user int64, requester string, x, y int, p priority) (*job, bool) {
+	return c.enqueueJob(&job{
+		Requester: requester,
+		User:      user,
+		Chat:      b.chatID,
+		X:         x,
+		Y:         y,
+		Priority:  p,
+		bot:       b,
+	})
+}
+
+// enqueueJob gives j an ID and adds it to the camera queue if there is room
+// for its priority.
+func (c *camera) enqueueJob(j *job) (*job, bool) {
+	c.smu.Lock()
+	if stopping.Load() || !c.hasRoom(j.Priority) {
+		c.smu.Unlock()
+		return nil, false
+	}
+	j.ID = int(atomic.AddInt64(&jobSeq, 1))
+	j.Added = clk.Now()
+	c.jobs = append(c.jobs, j)
+	c.smu.Unlock()
+
+	c.wakeWorker()
+	return j, true
+}
+
+// push appends j to the queue and wakes the worker.
+func (c *camera) push(j *job) {
+	c.smu.Lock()
+	c.jobs = append(c.jobs, j)
+	c.smu.Unlock()
+
+	c.wakeWorker()
+}
+
+func (c *camera) wakeWorker() {
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+// next marks the waiting job with the highest priority as started and
+// returns it, jobs of the same priority run in order they were added.
+// shutdown sets stopping under c.smu, so no job starts after it waits for
+// inflight.
+func (c *camera) next() *job {
+	c.smu.Lock()
+	defer c.smu.Unlock()
+	if stopping.Load() {
+		return nil
+	}
+	var next *job
+	for _, j := range c.jobs {
+		if !j.Started && (next == nil || j.Priority > next.Priority) {
+			next = j
+		}
+	}
+	if next != nil {
+		next.Started = true
+		inflight.Add(1)
+	}
+	return next
+}
+
+func (c *camera) finish(j *job) {
+	c.smu.Lock()
+	defer c.smu.Unlock()
+	for i, v := range c.jobs {
+		if v == j {
+			c.jobs = append(c.jobs[:i], c.jobs[i+1:]...)
+			return
+		}
+	}
+}
+
+// worker takes queued photos one by one.
+func (c *camera) worker() {
+	for range c.wake {
+		for j := c.next(); j != nil; j = c.next() {
+			j.bot.AccessCamera(c, j)
+			c.finish(j)
+			inflight.Done()
+		}
+	}
+}
+
+func (c *camera) queueLen() int {
+	c.smu.Lock()
+	defer c.smu.Unlock()
+	return len(c.jobs)
+}
+
+// queued returns jobs in order they will run, the started one first.
+func (c *camera) queued() []job {
+	c.smu.Lock()
+	defer c.smu.Unlock()
+
+	jobs := make([]job, len(c.jobs))
+	for i, j := range c.jobs {
+		jobs[i] = *j
+	}
+	sort.SliceStable(jobs, func(i, k int) bool {
+		if jobs[i].Started != jobs[k].Started {
+			return jobs[i].Started
+		}
+		return jobs[i].Priority > jobs[k].Priority
+	})
+	return jobs
+}
+
+// cancelJob removes job id from its queue if it has not started yet. Only the
+// requester or an admin may cancel it. It returns the message key to reply.
+func cancelJob(id int, user int64) string {
+	for _, name := range cameraNames {
+		c := cameras[name]
+		c.smu.Lock()
+		for i, j := range c.jobs {
+			if j.ID != id {
+				continue
+			}
+			key := "job_cancelled"
+			if j.User != user && !isAdmin(user) {
+				key = "job_unknown"
+			} else if j.Started {
+				key = "job_started"
+			} else {
+				c.jobs = append(c.jobs[:i], c.jobs[i+1:]...)
+				log.Info().Str("camera", c.Name).Int("job", id).Int64("user", user).Msg("Cancelled job.")
+			}
+			c.smu.Unlock()
+			return key
+		}
+		c.smu.Unlock()
+	}
+	return "job_unknown"
+}
+
+func cancelKeyboard(lang string, id int) echotron.InlineKeyboardMarkup {
+	return echotron.InlineKeyboardMarkup{
+		InlineKeyboard: [][]echotron.InlineKeyboardButton{
+			{{Text: tr(lang, "cancel_button"), CallbackData: fmt.Sprintf("cancel:%v", id)}},
+		},
+	}
+}
+
+// replyQueued sends message key with a button cancelling job j.
+func (b *Bot) replyQueued(j *job, key string, args ...interface{}) {
+	opts := &echotron.MessageOptions{ReplyMarkup: cancelKeyboard(b.language(), j.ID)}
+	if _, err := b.SendMessage(b.tr(key, args...), b.chatID, opts); err != nil {
+		log.Error().Err(err).Msg("Failed to send message.")
+		time.Sleep(10 * time.Second)
+	}
+}
+
+// handleCallback handles inline buttons, currently only job cancelling.
+func (b *Bot) handleCallback(query *echotron.CallbackQuery) {
+	lang := userLanguage(query.From.ID, query.From.LanguageCode)
+	data, ok := strings.CutPrefix(query.Data, "cancel:")
+	id, err := strconv.Atoi(data)
+	if !ok || err != nil {
+		log.Warn().Str("data", query.Data).Msg("Unknown callback data.")
+		return
+	}
+
+	key := cancelJob(id, query.From.ID)
+	if _, err := b.AnswerCallbackQuery(query.ID, &echotron.CallbackQueryOptions{Text: tr(lang, key, id)}); err != nil {
+		log.Error().Err(err).Msg("Failed to answer callback query.")
+	}
+	if key != "job_unknown" && query.Message != nil {
+		markup := &echotron.MessageReplyMarkup{ReplyMarkup: echotron.InlineKeyboardMarkup{InlineKeyboard: [][]echotron.InlineKeyboardButton{}}}
+		if _, err := b.EditMessageReplyMarkup(echotron.NewMessageID(b.chatID, query.Message.ID), markup); err != nil {
+			log.Error().Err(err).Msg("Failed to remove cancel button.")
+		}
+	}
+}
+
+func (b *Bot) cmdQueue(update *echotron.Update, args []string) stateFn {
+	var lines []string
+	for _, name := range cameraNames {
+		place := 0
+		for _, j := range cameras[name].queued() {
+			if !j.Started {
+				place++
+			}
+			if j.User != update.Message.From.ID {
+				continue
+			}
+			if j.Started {
+				lines = append(lines, b.tr("queue_job_running", j.ID, name, j.X, j.Y))
+			} else {
+				lines = append(lines, b.tr("queue_job", j.ID, name, j.X, j.Y, place))
+			}
+		}
+	}
+	if len(lines) == 0 {
+		b.reply("queue_none", update.Message.From.FirstName)
+	} else {
+		b.reply("queue_list", update.Message.From.FirstName, strings.Join(lines, "\n"))
+	}
+	return b.handleLogin
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+func (s breakerState) String() string {
+	switch s {
+	case breakerOpen:
+		return "open"
+	case breakerHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+type failureKind int
+
+const (
+	failureMotor failureKind = iota
+	failureFetch
+)
+
+func (k failureKind) String() string {
+	if k == failureMotor {
+		return "motor"
+	}
+	return "fetch"
+}
+
+// breaker stops sending jobs to the camera after too many consecutive
+// failures. After cooldown one trial job is let through (half-open), its
+// result either closes the breaker again or keeps it open.
+type breaker struct {
+	name      string
+	mu        sync.Mutex
+	state     breakerState
+	failures  int
+	threshold int
+	cooldown  time.Duration
+	openedAt  time.Time
+	lastErr   error
+	// trial is set while the half-open trial job is running.
+	trial bool
+}
+
+func newBreaker(name string) *breaker {
+	return &breaker{
+		name:      name,
+		threshold: envInt("BREAKER_THRESHOLD", 3),
+		cooldown:  envDuration("BREAKER_COOLDOWN", 10*time.Minute),
+	}
+}
+
+// available reports whether jobs may be queued for the camera, which is when
+// the breaker is closed or its cooldown is over.
+func (br *breaker) available() bool {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+	return br.state != breakerOpen || clk.Now().Sub(br.openedAt) >= br.cooldown
+}
+
+// allow reports whether a job may use the camera now. While half-open only
+// the trial job is allowed until success or failure reports its result.
+func (br *breaker) allow() bool {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+
+	if br.state == breakerOpen && clk.Now().Sub(br.openedAt) >= br.cooldown {
+		br.state = breakerHalfOpen
+		log.Info().Str("camera", br.name).Msg("Camera breaker is half-open, trying next job.")
+	}
+	switch br.state {
+	case breakerOpen:
+		return false
+	case breakerHalfOpen:
+		if br.trial {
+			return false
+		}
+		br.trial = true
+	}
+	return true
+}
+
+func (br *breaker) success() {
+	br.mu.Lock()
+	recovered := br.state != breakerClosed
+	br.state = breakerClosed
+	br.trial = false
+	br.failures = 0
+	br.lastErr = nil
+	br.mu.Unlock()
+
+	if recovered {
+		log.Info().Str("camera", br.name).Msg("Camera recovered, closing breaker.")
+		resolveAlert("breaker:" + br.name)
+	}
+}
+
+func (br *breaker) failure(err error) {
+	br.mu.Lock()
+	br.failures++
+	br.lastErr = err
+	br.trial = false
+	tripped := br.state == breakerHalfOpen || (br.state == breakerClosed && br.failures >= br.threshold)
+	if tripped {
+		br.state = breakerOpen
+		br.openedAt = clk.Now()
+	}
+	failures := br.failures
+	br.mu.Unlock()
+
+	if tripped {
+		log.Error().Err(err).Str("camera", br.name).Int("failures", failures).Msg("Camera breaker opened.")
+		raiseAlert("breaker:"+br.name, "breaker_open", br.name, failures, br.cooldown, err)
+	}
+}
+
+// alert returns key and message of the alert raised for failure of camera.
+func (k failureKind) alert(camera string) (string, string) {
+	if k == failureMotor {
+		return "motor:" + camera, "alert_motor"
+	}
+	return "fetch:" + camera, "alert_phone_init"
+}
+
+func classifyFailure(err error) failureKind {
+	var merr *motorError
+	if errors.As(err, &merr) {
+		return failureMotor
+	}
+	return failureFetch
+}
+
+// recover tries to bring the hardware back after failure of given kind.
+func (c *camera) recover(kind failureKind) error {
+	if kind == failureMotor {
+		return c.home()
+	}
+	return exec.Command(c.Init).Run()
+}
+
+// captureWithRecovery captures a photo, on failure it reinitializes the part of
+// hardware that failed and tries once more. The result is fed to the breaker.
+func (c *camera) captureWithRecovery(ctx context.Context, x, y int) ([]byte, error) {
+	return c.withRecovery(ctx, func() ([]byte, error) {
+		return c.capture(ctx, x, y)
+	})
+}
+
+// recordWithRecovery records a clip like captureWithRecovery takes a photo.
+func (c *camera) recordWithRecovery(ctx context.Context, x, y, secs int) ([]byte, error) {
+	return c.withRecovery(ctx, func() ([]byte, error) {
+		return c.record(ctx, x, y, secs)
+	})
+}
+
+// withRecovery runs take, on failure it raises an alert, reinitializes the
+// part of hardware that failed and runs take once more.
+func (c *camera) withRecovery(ctx context.Context, take func() ([]byte, error)) ([]byte, error) {
+	data, err := take()
+	if err == nil {
+		c.healthy()
+		return data, nil
+	}
+	if ctx.Err() != nil {
+		// Cancelled by shutdown, the camera did not fail.
+		return nil, err
+	}
+
+	kind := classifyFailure(err)
+	log.Warn().Err(err).Str("camera", c.Name).Stringer("kind", kind).Msg("Capture failed, reinitializing camera.")
+	key, msg := kind.alert(c.Name)
+	raiseAlert(key, msg, c.Name, err)
+	if rerr := c.recover(kind); rerr != nil {
+		log.Error().Err(rerr).Str("camera", c.Name).Stringer("kind", kind).Msg("Failed to reinitialize camera.")
+	}
+
+	data, err = take()
+	if err != nil {
+		c.breaker.failure(err)
+		return nil, err
+	}
+	c.healthy()
+	return data, nil
+}
+
+// healthy records a successful capture, resolving alerts of the camera.
+func (c *camera) healthy() {
+	c.breaker.success()
+	for _, kind := range []failureKind{failureMotor, failureFetch} {
+		key, _ := kind.alert(c.Name)
+		resolveAlert(key)
+	}
+}
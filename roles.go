@@ -0,0 +1,225 @@
+package main
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/NicoNex/echotron/v3"
+	"github.com/rs/zerolog/log"
+)
+
+type role int
+
+const (
+	roleNone role = iota
+	roleGuest
+	roleMember
+	roleAdmin
+)
+
+var roleNames = map[role]string{
+	roleNone:   "none",
+	roleGuest:  "guest",
+	roleMember: "member",
+	roleAdmin:  "admin",
+}
+
+func (r role) String() string {
+	return roleNames[r]
+}
+
+func parseRole(s string) (role, bool) {
+	for r, name := range roleNames {
+		if strings.EqualFold(s, name) {
+			return r, true
+		}
+	}
+	return roleNone, false
+}
+
+// member is a user talking to the bot in a chat. Private chats have a single
+// member, in groups every user has own role and conversation state.
+type member struct {
+	id   int64
+	name string
+	// role is granted by logging in within this chat.
+	role  role
+	state stateFn
+	// partial holds arguments given with the command that started the
+	// current prompt, they are prepended to the prompt answer.
+	partial []string
+	// pending is set while the member has to answer a prompt.
+	pending     bool
+	promptGen   int
+	promptTimer timer
+}
+
+// groupSettings are stored per group chat.
+type groupSettings struct {
+	// Capture is the lowest role allowed to move the camera.
+	Capture role `json:"capture"`
+	// Roles are granted by admins inside the group.
+	Roles map[int64]role `json:"roles"`
+}
+
+// guests holds users logged in with a guest password, so they keep the role
+// in groups.
+var guests = struct {
+	sync.Mutex
+	users map[int64]bool
+}{users: make(map[int64]bool)}
+
+func addGuest(userID int64) {
+	guests.Lock()
+	guests.users[userID] = true
+	guests.Unlock()
+}
+
+func removeGuest(userID int64) {
+	guests.Lock()
+	delete(guests.users, userID)
+	guests.Unlock()
+}
+
+func isGuest(userID int64) bool {
+	guests.Lock()
+	defer guests.Unlock()
+	return guests.users[userID]
+}
+
+// adminUsers holds users logged in with the admin password. Like guests they
+// lose the role when the session they logged in with expires.
+var adminUsers = struct {
+	sync.Mutex
+	users map[int64]bool
+}{users: make(map[int64]bool)}
+
+func addAdminUser(userID int64) {
+	adminUsers.Lock()
+	adminUsers.users[userID] = true
+	adminUsers.Unlock()
+}
+
+func removeAdminUser(userID int64) {
+	adminUsers.Lock()
+	delete(adminUsers.users, userID)
+	adminUsers.Unlock()
+}
+
+func isAdmin(userID int64) bool {
+	adminUsers.Lock()
+	defer adminUsers.Unlock()
+	return adminUsers.users[userID]
+}
+
+func groupConfig(chatID int64) groupSettings {
+	var g groupSettings
+	viewStore(func(p *persistent) {
+		g = p.Groups[chatID]
+	})
+	if g.Capture == roleNone {
+		g.Capture = roleGuest
+	}
+	return g
+}
+
+// member returns the member with userID, creating it on first message.
+func (b *Bot) member(user *echotron.User) *member {
+	m, ok := b.users[user.ID]
+	if !ok {
+		m = &member{id: user.ID}
+		if b.group {
+			// Users log in privately, groups only check their roles.
+			m.state = b.handleLogin
+		} else {
+			m.state = b.handleMessage
+		}
+		b.users[user.ID] = m
+	}
+	m.name = user.FirstName
+	return m
+}
+
+// role is the role of the current member: the best of the chat login, global
+// admin and guest logins and the role granted in the group.
+func (b *Bot) role() role {
+	r := b.user.role
+	if isAdmin(b.user.id) {
+		return roleAdmin
+	}
+	if isGuest(b.user.id) && r < roleGuest {
+		r = roleGuest
+	}
+	if b.group {
+		if g := groupConfig(b.chatID).Roles[b.user.id]; g > r {
+			r = g
+		}
+	}
+	return r
+}
+
+// mayCapture reports whether the current member may move the camera.
+func (b *Bot) mayCapture() bool {
+	return !b.group || b.role() >= groupConfig(b.chatID).Capture
+}
+
+func (b *Bot) cmdRole(update *echotron.Update, args []string) stateFn {
+	if !b.group {
+		b.reply("group_only", update.Message.From.FirstName)
+		return b.handleLogin
+	}
+	target := update.Message.ReplyToMessage
+	if target == nil || target.From == nil {
+		b.reply("role_usage", update.Message.From.FirstName)
+		return b.handleLogin
+	}
+	// The argument schema makes args a single role.
+	r, _ := parseRole(args[0])
+	if r == roleAdmin {
+		log.Warn().Strs("args", args).Msg("Invalid role.")
+		b.reply("role_usage", update.Message.From.FirstName)
+		return b.handleLogin
+	}
+
+	updateStore(func(p *persistent) {
+		g := p.Groups[b.chatID]
+		if g.Roles == nil {
+			g.Roles = make(map[int64]role)
+		}
+		if r == roleNone {
+			delete(g.Roles, target.From.ID)
+		} else {
+			g.Roles[target.From.ID] = r
+		}
+		p.Groups[b.chatID] = g
+	})
+	log.Info().Strs("user", []string{update.Message.From.FirstName, update.Message.From.LastName, update.Message.From.Username}).Int64("chat", b.chatID).Int64("target", target.From.ID).Stringer("role", r).Msg("Changed group role.")
+	b.reply("role_set", update.Message.From.FirstName, userName(target.From), r)
+	return b.handleLogin
+}
+
+func (b *Bot) cmdCapture(update *echotron.Update, args []string) stateFn {
+	if !b.group {
+		b.reply("group_only", update.Message.From.FirstName)
+		return b.handleLogin
+	}
+	if len(args) == 0 {
+		b.reply("capture_current", update.Message.From.FirstName, groupConfig(b.chatID).Capture)
+		return b.handleLogin
+	}
+	r, _ := parseRole(args[0])
+	if r == roleNone {
+		log.Warn().Strs("args", args).Msg("Invalid role.")
+		b.reply("capture_usage", update.Message.From.FirstName)
+		return b.handleLogin
+	}
+
+	updateStore(func(p *persistent) {
+		g := p.Groups[b.chatID]
+		g.Capture = r
+		p.Groups[b.chatID] = g
+	})
+	log.Info().Strs("user", []string{update.Message.From.FirstName, update.Message.From.LastName, update.Message.From.Username}).Int64("chat", b.chatID).Stringer("role", r).Msg("Changed group capture role.")
+	b.reply("capture_set", update.Message.From.FirstName, r)
+	return b.handleLogin
+}
@@ -0,0 +1,760 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/NicoNex/echotron/v3"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+var fake *fakeAPI
+var fakeClk *fakeClock
+
+// webhook receives alerts posted to ALERT_WEBHOOK.
+var webhook = make(chan map[string]interface{}, 16)
+
+// forecast is the current weather served by the Open-Meteo style stub.
+var forecast struct {
+	sync.Mutex
+	clouds float64
+	// visibility is in metres like in Open-Meteo.
+	visibility float64
+	// place is the location weather was last asked for.
+	place string
+}
+
+const waitTimeout = 10 * time.Second
+
+// TestMain runs the whole bot against the fake Bot API with a stub motor
+// driver and a camera serving a generated JPEG.
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "cameratgbot")
+	if err != nil {
+		panic(err)
+	}
+
+	driver := filepath.Join(dir, "driver.sh")
+	if err := os.WriteFile(driver, []byte("#!/bin/sh\nexit 0\n"), 0o755); err != nil {
+		panic(err)
+	}
+	// ffmpeg stub writes the clip to its last argument.
+	ffmpeg := filepath.Join(dir, "ffmpeg.sh")
+	if err := os.WriteFile(ffmpeg, []byte("#!/bin/sh\nfor last; do :; done\nprintf clip > \"$last\"\n"), 0o755); err != nil {
+		panic(err)
+	}
+
+	var photo bytes.Buffer
+	jpeg.Encode(&photo, image.NewRGBA(image.Rect(0, 0, 64, 48)), nil)
+	cam := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/jpeg")
+		w.Write(photo.Bytes())
+	}))
+
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&payload)
+		webhook <- payload
+	}))
+
+	meteo := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forecast.Lock()
+		defer forecast.Unlock()
+		forecast.place = r.URL.Query().Get("latitude") + "," + r.URL.Query().Get("longitude")
+		fmt.Fprintf(w, `{"current": {"time": "2026-03-01T21:00", "cloud_cover": %v, "visibility": %v}}`, forecast.clouds, forecast.visibility)
+	}))
+
+	camerasFile := filepath.Join(dir, "cameras.json")
+	config := fmt.Sprintf(`[{"name": "main", "driver": %q, "init": %q, "url": %q, "lat": 57.4, "lng": 21.6}]`, driver, driver, cam.URL)
+	if err := os.WriteFile(camerasFile, []byte(config), 0o644); err != nil {
+		panic(err)
+	}
+
+	os.Setenv("TOKEN", "test")
+	os.Setenv("PASSWORD", "secret")
+	os.Setenv("CAMERAS_FILE", camerasFile)
+	os.Setenv("STATE_FILE", filepath.Join(dir, "state.json"))
+	os.Setenv("PROMPT_TIMEOUT", "5m")
+	os.Setenv("ALERT_WEBHOOK", hook.URL)
+	os.Setenv("WEATHER_URL", meteo.URL)
+	os.Setenv("FFMPEG", ffmpeg)
+	log.Logger = zerolog.New(io.Discard)
+
+	fake = newFakeAPI()
+	fake.install()
+	fakeClk = newFakeClock(time.Date(2026, 3, 1, 7, 29, 0, 0, time.Local))
+	clk = fakeClk
+
+	loadConfig()
+	initCameras()
+	diceDelay = 0
+	botUsername = "CameraBot"
+	setSunsetTime(21, 5)
+
+	go poll(echotron.NewAPI(os.Getenv("TOKEN")))
+	go runScheduler()
+	// Updates of the first poll are dropped.
+	fake.waitCalls("getUpdates", 2)
+
+	code := m.Run()
+	cam.Close()
+	hook.Close()
+	meteo.Close()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+var lastID int64 = 100
+
+// nextID returns a new chat or user ID, so tests can run several times.
+func nextID() int64 {
+	return atomic.AddInt64(&lastID, 1)
+}
+
+func user(id int64, name string) *echotron.User {
+	return &echotron.User{ID: id, FirstName: name, LanguageCode: "en"}
+}
+
+func expectText(t *testing.T, got sent, key string, args ...interface{}) {
+	t.Helper()
+	want := tr("en", key, args...)
+	if got.Method != "sendMessage" || got.Text != want {
+		t.Fatalf("want message %q, got %v %q", want, got.Method, got.Text)
+	}
+}
+
+// split separates sent photos from messages, their order depends on the queue.
+func split(msgs []sent) (photos, texts []sent) {
+	for _, m := range msgs {
+		if m.Method == "sendPhoto" {
+			photos = append(photos, m)
+		} else {
+			texts = append(texts, m)
+		}
+	}
+	return photos, texts
+}
+
+func expectPhoto(t *testing.T, got sent, caption string) {
+	t.Helper()
+	if got.Caption != caption {
+		t.Fatalf("want caption %q, got %q", caption, got.Caption)
+	}
+	if len(got.Files) != 1 {
+		t.Fatalf("want one photo, got %v files", len(got.Files))
+	}
+	if _, err := jpeg.Decode(bytes.NewReader(got.Files[0])); err != nil {
+		t.Fatalf("photo is not a JPEG: %v", err)
+	}
+}
+
+// jobID reads the job ID from the cancel button of a queued message.
+func jobID(t *testing.T, s sent) int {
+	t.Helper()
+	var markup echotron.InlineKeyboardMarkup
+	if err := json.Unmarshal([]byte(s.ReplyMarkup), &markup); err != nil || len(markup.InlineKeyboard) == 0 {
+		t.Fatalf("message has no cancel button: %+v", s)
+	}
+	var id int
+	if _, err := fmt.Sscanf(markup.InlineKeyboard[0][0].CallbackData, "cancel:%d", &id); err != nil {
+		t.Fatalf("bad cancel button data: %v", err)
+	}
+	return id
+}
+
+func login(t *testing.T, chat int64, u *echotron.User, password string) {
+	t.Helper()
+	fake.message(chat, u, password)
+	expectText(t, fake.wait(t, chat, 1, waitTimeout)[0], "welcome", u.FirstName)
+}
+
+func TestLoginRequired(t *testing.T) {
+	chat := nextID()
+	u := user(chat, "Anna")
+
+	fake.message(chat, u, "/photo 10 20")
+	expectText(t, fake.wait(t, chat, 1, waitTimeout)[0], "hello", "Anna")
+
+	fake.message(chat, u, "wrong")
+	expectText(t, fake.wait(t, chat, 1, waitTimeout)[0], "hello", "Anna")
+}
+
+func TestAdminPhoto(t *testing.T) {
+	chat := nextID()
+	u := user(chat, "Borīss")
+	login(t, chat, u, "secret")
+
+	fake.message(chat, u, "/photo 120 45")
+	photos, texts := split(fake.wait(t, chat, 2, waitTimeout))
+	if len(photos) != 1 || len(texts) != 1 {
+		t.Fatalf("want a photo and a message, got %+v", append(photos, texts...))
+	}
+	expectText(t, texts[0], "photo_queued", "Borīss", jobID(t, texts[0]))
+	expectPhoto(t, photos[0], "X: 120 Y: 45")
+	if stamp := fakeClk.Now().Format("2006:01:02 15:04:05"); !bytes.Contains(photos[0].Files[0], []byte(stamp)) {
+		t.Fatalf("photo has no EXIF time %v", stamp)
+	}
+	if comment := commentValue("Y: 45 requested by Borīss"); !bytes.Contains(photos[0].Files[0], comment) || !bytes.HasPrefix(comment, []byte("UNICODE\x00")) {
+		t.Fatalf("photo has no UNICODE EXIF comment %q", comment)
+	}
+
+	if pos := defaultCamera().position(); pos.X != 120 || pos.Y != 45 {
+		t.Fatalf("camera is at %+v, want 120 45", pos)
+	}
+}
+
+func TestGuestPrompt(t *testing.T) {
+	chat := nextID()
+	u := user(chat, "Vera")
+	pass, _ := currentGuestPass()
+	login(t, chat, u, pass)
+
+	fake.message(chat, u, "/photo")
+	expectText(t, fake.wait(t, chat, 1, waitTimeout)[0], "photo_prompt", "Vera")
+
+	fake.message(chat, u, "400 10")
+	expectText(t, fake.wait(t, chat, 1, waitTimeout)[0], "x_range", "Vera")
+
+	fake.message(chat, u, "30 10")
+	photos, _ := split(fake.wait(t, chat, 2, waitTimeout))
+	if len(photos) != 1 {
+		t.Fatalf("want one photo, got %v", len(photos))
+	}
+	expectPhoto(t, photos[0], "X: 30 Y: 10")
+
+	fake.message(chat, u, "/eventcreate 1 2 03:04")
+	expectText(t, fake.wait(t, chat, 1, waitTimeout)[0], "guest_denied", "Vera")
+}
+
+func TestEvents(t *testing.T) {
+	chat := nextID()
+	u := user(chat, "Gleb")
+	login(t, chat, u, "secret")
+
+	fake.message(chat, u, "/eventcreate 120 45 07:30")
+	expectText(t, fake.wait(t, chat, 1, waitTimeout)[0], "event_created", "Gleb", "main", 120, 45, 7, 30, false)
+
+	fake.message(chat, u, "/eventsunset")
+	expectText(t, fake.wait(t, chat, 1, waitTimeout)[0], "sunset_exists", "Gleb")
+
+	fake.message(chat, u, "/eventdelete")
+	expectText(t, fake.wait(t, chat, 1, waitTimeout)[0], "event_deleted", "Gleb", 120, 45, 7, 30, false)
+
+	fake.message(chat, u, "/eventsunset")
+	expectText(t, fake.wait(t, chat, 1, waitTimeout)[0], "sunset_prompt")
+	fake.message(chat, u, "200 x")
+	expectText(t, fake.wait(t, chat, 1, waitTimeout)[0], "sunset_invalid", "Gleb")
+	fake.message(chat, u, "400 5")
+	expectText(t, fake.wait(t, chat, 1, waitTimeout)[0], "x_range", "Gleb")
+	fake.message(chat, u, "200 5")
+	expectText(t, fake.wait(t, chat, 1, waitTimeout)[0], "sunset_created", "main", 200, 5)
+
+	fake.message(chat, u, "/sunsettime")
+	expectText(t, fake.wait(t, chat, 1, waitTimeout)[0], "sunset_time", "Gleb", 21, 5)
+
+	fake.message(chat, u, "/eventdelete")
+	fake.wait(t, chat, 1, waitTimeout)
+}
+
+func TestCommandArgs(t *testing.T) {
+	chat := nextID()
+	u := user(chat, "Rudolfs")
+	login(t, chat, u, "secret")
+
+	for text, usage := range map[string]string{
+		"/photo 10":                        "/photo [camera] [X Y]",
+		"/photo main x 20":                 "/photo [camera] [X Y]",
+		"/eventcreate 1 2 3 4":             "/eventcreate [camera] [X Y HH:MM]",
+		"/eventsunset 200 5 cloudy":        "/eventsunset [camera] [X Y] [clouds<N|visibility>N]...",
+		"/zoneadd main 1 2 3":              "/zoneadd [camera] X1 Y1 X2 Y2",
+		"/role boss":                       "/role guest|member|none",
+		"/cancel first":                    "/cancel [id]",
+		"/where main":                      "/where",
+		"/clip main 10 20 5 clouds<80 now": "/clip [camera] [X Y seconds]",
+	} {
+		fake.message(chat, u, text)
+		expectText(t, fake.wait(t, chat, 1, waitTimeout)[0], "command_usage", "Rudolfs", usage)
+	}
+
+	fake.message(chat, u, "/zoneadd main 300 80 310 90")
+	expectText(t, fake.wait(t, chat, 1, waitTimeout)[0], "zone_added", "Rudolfs", newZone(300, 80, 310, 90), "main")
+	fake.message(chat, u, "/zonedel 9")
+	expectText(t, fake.wait(t, chat, 1, waitTimeout)[0], "zonedel_usage", "Rudolfs")
+	updateStore(func(p *persistent) {
+		p.Zones["main"] = nil
+	})
+}
+
+func TestDice(t *testing.T) {
+	chat := nextID()
+	u := user(chat, "Dana")
+	login(t, chat, u, "secret")
+
+	fake.mu.Lock()
+	fake.dice = []int{2, 3}
+	fake.mu.Unlock()
+
+	fake.message(chat, u, "/dice")
+	msgs := fake.wait(t, chat, 4, waitTimeout)
+	if msgs[0].Method != "sendDice" || msgs[1].Method != "sendDice" {
+		t.Fatalf("want two dice first, got %+v", msgs[:2])
+	}
+	photos, texts := split(msgs[2:])
+	if len(photos) != 1 || len(texts) != 1 {
+		t.Fatalf("want a photo and a message, got %+v", msgs[2:])
+	}
+	expectText(t, texts[0], "dice_photo", "Dana", 120, 45, jobID(t, texts[0]))
+	expectPhoto(t, photos[0], "X: 120 Y: 45")
+}
+
+func TestCancelAndTimeout(t *testing.T) {
+	chat := nextID()
+	u := user(chat, "Egor")
+	login(t, chat, u, "secret")
+
+	fake.message(chat, u, "/cancel")
+	expectText(t, fake.wait(t, chat, 1, waitTimeout)[0], "cancel_none", "Egor")
+
+	fake.message(chat, u, "/photo")
+	expectText(t, fake.wait(t, chat, 1, waitTimeout)[0], "photo_prompt", "Egor")
+	fake.message(chat, u, "/cancel")
+	expectText(t, fake.wait(t, chat, 1, waitTimeout)[0], "cancelled", "Egor")
+
+	fake.message(chat, u, "/eventcreate")
+	expectText(t, fake.wait(t, chat, 1, waitTimeout)[0], "event_prompt", "Egor")
+	fakeClk.waitTimer(t, fakeClk.Now().Add(promptTimeout))
+	fakeClk.Advance(4 * time.Minute)
+	fake.quiet(t, chat, 100*time.Millisecond)
+	fakeClk.Advance(time.Minute)
+	expectText(t, fake.wait(t, chat, 1, waitTimeout)[0], "prompt_expired", "Egor")
+
+	fake.message(chat, u, "1 2 3 4")
+	expectText(t, fake.wait(t, chat, 1, waitTimeout)[0], "unknown_command", "Egor", "1 2 3 4")
+}
+
+func TestGroup(t *testing.T) {
+	group := -nextID()
+	admin := user(nextID(), "Fedor")
+	stranger := user(nextID(), "Galina")
+	login(t, admin.ID, admin, "secret")
+
+	fake.message(group, stranger, "/photo@CameraBot 10 10")
+	expectText(t, fake.wait(t, group, 1, waitTimeout)[0], "group_login", "Galina", "CameraBot")
+
+	fake.message(group, stranger, "just chatting")
+	fake.message(group, admin, "/where@OtherBot")
+	fake.quiet(t, group, 200*time.Millisecond)
+
+	fake.message(group, admin, "/capture@CameraBot admin")
+	expectText(t, fake.wait(t, group, 1, waitTimeout)[0], "capture_set", "Fedor", roleAdmin)
+
+	fake.message(group, admin, "/photo@CameraBot 15 5")
+	photos, _ := split(fake.wait(t, group, 2, waitTimeout))
+	if len(photos) != 1 {
+		t.Fatalf("want one photo, got %v", len(photos))
+	}
+	expectPhoto(t, photos[0], "X: 15 Y: 5")
+}
+
+func TestGroupAdminExpiry(t *testing.T) {
+	group := -nextID()
+	admin := user(nextID(), "Zane")
+	notified := user(nextID(), "Uldis")
+	login(t, admin.ID, admin, "secret")
+	// Chats from ADMIN_CHATS are only notified, they are not admins.
+	addAdmin(notified.ID)
+
+	fake.message(group, notified, "/capture@CameraBot")
+	expectText(t, fake.wait(t, group, 1, waitTimeout)[0], "group_login", "Uldis", "CameraBot")
+	fake.message(group, admin, "/capture@CameraBot")
+	expectText(t, fake.wait(t, group, 1, waitTimeout)[0], "capture_current", "Zane", roleGuest)
+
+	// The admin role ends with the private session it was granted in.
+	fakeClk.Advance(8 * time.Hour)
+	waitExpired(t, admin.ID)
+	fake.message(group, admin, "/capture@CameraBot")
+	expectText(t, fake.wait(t, group, 1, waitTimeout)[0], "group_login", "Zane", "CameraBot")
+}
+
+func TestMediaGroup(t *testing.T) {
+	chat := nextID()
+	api := echotron.NewAPI("test")
+	media := []echotron.GroupableInputMedia{
+		echotron.InputMediaPhoto{Type: echotron.MediaTypePhoto, Media: echotron.NewInputFileBytes("a.jpg", []byte{1}), Caption: "album"},
+		echotron.InputMediaPhoto{Type: echotron.MediaTypePhoto, Media: echotron.NewInputFileBytes("b.jpg", []byte{2})},
+	}
+	if _, err := api.SendMediaGroup(chat, media, nil); err != nil {
+		t.Fatal(err)
+	}
+	got := fake.wait(t, chat, 1, waitTimeout)[0]
+	if got.Method != "sendMediaGroup" || got.Caption != "album" || len(got.Files) != 2 || got.Files[1][0] != 2 {
+		t.Fatalf("unexpected media group %+v", got)
+	}
+}
+
+func TestEventFires(t *testing.T) {
+	chat := nextID()
+	u := user(chat, "Hugo")
+	login(t, chat, u, "secret")
+
+	now := fakeClk.Now()
+	at := time.Date(now.Year(), now.Month(), now.Day(), now.Hour(), now.Minute()+1, 0, 0, now.Location())
+	fake.message(chat, u, fmt.Sprintf("/eventcreate 60 30 %v:%v", at.Hour(), at.Minute()))
+	expectText(t, fake.wait(t, chat, 1, waitTimeout)[0], "event_created", "Hugo", "main", 60, 30, at.Hour(), at.Minute(), false)
+
+	for day := 0; day < 2; day++ {
+		fakeClk.waitTimer(t, at)
+		fakeClk.Advance(at.Sub(fakeClk.Now()))
+		expectPhoto(t, fake.wait(t, chat, 1, waitTimeout)[0], "X: 60 Y: 30")
+		fake.quiet(t, chat, 100*time.Millisecond)
+		at = at.AddDate(0, 0, 1)
+	}
+
+	// The event outlives the session, which expired meanwhile.
+	waitExpired(t, chat)
+	login(t, chat, u, "secret")
+	fake.message(chat, u, "/eventdelete")
+	expectText(t, fake.wait(t, chat, 1, waitTimeout)[0], "event_deleted", "Hugo", 60, 30, at.Hour(), at.Minute(), false)
+}
+
+func TestEventCatchUp(t *testing.T) {
+	chat, missedChat := nextID(), nextID()
+	u := user(chat, "Jana")
+	login(t, chat, u, "secret")
+
+	// Events saved by a bot that was down for two hours, one of them due
+	// within the catch-up window.
+	now := fakeClk.Now()
+	recent, missed := now.Add(-10*time.Minute), now.Add(-time.Hour)
+	updateStore(func(p *persistent) {
+		p.Events[chat] = event{X: 30, Y: 10, Hour: recent.Hour(), Minute: recent.Minute(), Owner: "Jana", Camera: "main", LastRun: now.Add(-2 * time.Hour)}
+		p.Events[missedChat] = event{X: 40, Y: 10, Hour: missed.Hour(), Minute: missed.Minute(), Owner: "Jana", Camera: "main", LastRun: now.Add(-2 * time.Hour)}
+	})
+	loadEvents()
+
+	expectPhoto(t, fake.wait(t, chat, 1, waitTimeout)[0], "X: 30 Y: 10")
+	fake.quiet(t, missedChat, 100*time.Millisecond)
+	for _, e := range scheduledEvents() {
+		if want := missed.Truncate(time.Minute).AddDate(0, 0, 1); e.chat == missedChat && !e.at.Equal(want) {
+			t.Fatalf("missed event scheduled at %v, want %v", e.at, want)
+		}
+	}
+
+	removeEvent(chat)
+	removeEvent(missedChat)
+}
+
+func TestEventCatchUpAfterDays(t *testing.T) {
+	chat := nextID()
+
+	// The bot was down for two days, today's run is within the catch-up
+	// window.
+	now := fakeClk.Now()
+	recent := now.Add(-10 * time.Minute)
+	updateStore(func(p *persistent) {
+		p.Events[chat] = event{X: 35, Y: 10, Hour: recent.Hour(), Minute: recent.Minute(), Owner: "Jana", Camera: "main", LastRun: now.Add(-49 * time.Hour)}
+	})
+	loadEvents()
+
+	expectPhoto(t, fake.wait(t, chat, 1, waitTimeout)[0], "X: 35 Y: 10")
+	expectNext(t, chat, recent.Truncate(time.Minute).AddDate(0, 0, 1))
+	removeEvent(chat)
+}
+
+func TestEventGrace(t *testing.T) {
+	chat := nextID()
+	// The scheduler reads eventCatchup under its lock.
+	scheduler.Lock()
+	catchup := eventCatchup
+	eventCatchup = 0
+	scheduler.Unlock()
+	defer func() {
+		scheduler.Lock()
+		eventCatchup = catchup
+		scheduler.Unlock()
+	}()
+
+	now := fakeClk.Now()
+	at := now.Truncate(time.Minute).Add(time.Minute)
+	addEvent(chat, event{X: 50, Y: 10, Hour: at.Hour(), Minute: at.Minute(), Owner: "Peteris", Camera: "main"})
+
+	// Timers wake a bit late, the event still runs without catch-up.
+	fakeClk.waitTimer(t, at)
+	fakeClk.Advance(at.Add(time.Second).Sub(now))
+	expectPhoto(t, fake.wait(t, chat, 1, waitTimeout)[0], "X: 50 Y: 10")
+	removeEvent(chat)
+}
+
+func TestSunsetRestart(t *testing.T) {
+	chat := nextID()
+	now := fakeClk.Now()
+	noon := time.Date(now.Year(), now.Month(), now.Day(), 12, 0, 0, 0, now.Location())
+	if !noon.After(now) {
+		noon = noon.AddDate(0, 0, 1)
+	}
+	fakeClk.Advance(noon.Sub(now))
+
+	// The bot restarts in the morning, before today's sunset is fetched.
+	sunsetAt.Lock()
+	sunsetAt.hour, sunsetAt.minute = 0, 0
+	sunsetAt.Unlock()
+	loadSunset()
+	updateStore(func(p *persistent) {
+		p.Events[chat] = event{X: 200, Y: 5, Sunset: true, Owner: "Olga", Camera: "main", LastRun: noon.Add(-15*time.Hour + 5*time.Minute)}
+	})
+	loadEvents()
+	expectNext(t, chat, time.Date(noon.Year(), noon.Month(), noon.Day(), 21, 5, 0, 0, noon.Location()))
+
+	setSunsetTime(21, 7)
+	expectNext(t, chat, time.Date(noon.Year(), noon.Month(), noon.Day(), 21, 7, 0, 0, noon.Location()))
+
+	setSunsetTime(21, 5)
+	removeEvent(chat)
+}
+
+// expectNext checks when the event of chat runs next.
+func expectNext(t *testing.T, chat int64, want time.Time) {
+	t.Helper()
+	for _, e := range scheduledEvents() {
+		if e.chat == chat {
+			if !e.at.Equal(want) {
+				t.Fatalf("event scheduled at %v, want %v", e.at, want)
+			}
+			return
+		}
+	}
+	t.Fatal("event is not scheduled")
+}
+
+func TestClip(t *testing.T) {
+	chat := nextID()
+	u := user(chat, "Maris")
+	login(t, chat, u, "secret")
+
+	fake.message(chat, u, "/clip 10 20 600")
+	expectText(t, fake.wait(t, chat, 1, waitTimeout)[0], "clip_length", "Maris", clipMax)
+
+	fake.message(chat, u, "/clip 10 20 5")
+	var clip, queued sent
+	for _, m := range fake.wait(t, chat, 2, waitTimeout) {
+		if m.Method == "sendVideo" {
+			clip = m
+		} else {
+			queued = m
+		}
+	}
+	id := jobID(t, queued)
+	expectText(t, queued, "clip_queued", "Maris", 5, id)
+	if clip.Caption != "X: 10 Y: 20" || len(clip.Files) != 1 || string(clip.Files[0]) != "clip" {
+		t.Fatalf("unexpected clip %+v", clip)
+	}
+
+	// Failed clips raise an alert like failed photos, the next clip resolves
+	// it. The worker reads ffmpeg after the job is queued.
+	stub := ffmpeg
+	ffmpeg = "false"
+	fake.message(chat, u, "/clip 10 20 5")
+	expectTexts(t, fake.wait(t, chat, 3, waitTimeout), "clip_failed", "alert_phone_init")
+	expectWebhook(t, "fetch:main", "firing")
+
+	ffmpeg = stub
+	fake.message(chat, u, "/clip 10 20 5")
+	expectTexts(t, fake.wait(t, chat, 3, waitTimeout), "alert_resolved")
+	expectWebhook(t, "fetch:main", "resolved")
+}
+
+// expectTexts checks that msgs contain messages with each of keys, ignoring
+// their arguments.
+func expectTexts(t *testing.T, msgs []sent, keys ...string) {
+	t.Helper()
+	for _, key := range keys {
+		prefix, _, _ := strings.Cut(tr("en", key), "%")
+		found := false
+		for _, m := range msgs {
+			found = found || m.Method == "sendMessage" && strings.HasPrefix(m.Text, prefix)
+		}
+		if !found {
+			t.Fatalf("no %v message in %+v", key, msgs)
+		}
+	}
+}
+
+func expectWebhook(t *testing.T, key, status string) {
+	t.Helper()
+	select {
+	case got := <-webhook:
+		if got["key"] != key || got["status"] != status {
+			t.Fatalf("want %v %v alert on webhook, got %v", key, status, got)
+		}
+	case <-time.After(waitTimeout):
+		t.Fatalf("no %v %v alert on webhook", key, status)
+	}
+}
+
+func TestSunsetWeather(t *testing.T) {
+	chat := nextID()
+	u := user(chat, "Liga")
+	login(t, chat, u, "secret")
+
+	fake.message(chat, u, "/eventsunset 200 5 clouds<80 visibility>10")
+	expectText(t, fake.wait(t, chat, 1, waitTimeout)[0], "sunset_created_if", "main", 200, 5, "clouds<80 visibility>10")
+
+	now := fakeClk.Now()
+	at := time.Date(now.Year(), now.Month(), now.Day(), 21, 5, 0, 0, now.Location())
+	if !at.After(now) {
+		at = at.AddDate(0, 0, 1)
+	}
+	for _, w := range []struct {
+		clouds, visibility float64
+		skipped            string
+	}{
+		{93.5, 20000, tr("en", "cond_clouds", 93.5, "<", 80)},
+		{40, 8000, tr("en", "cond_visibility", 8, ">", 10)},
+		{10, 30000, ""},
+	} {
+		forecast.Lock()
+		forecast.clouds, forecast.visibility = w.clouds, w.visibility
+		forecast.Unlock()
+
+		fakeClk.waitTimer(t, at)
+		fakeClk.Advance(at.Sub(fakeClk.Now()))
+		got := fake.wait(t, chat, 1, waitTimeout)[0]
+		forecast.Lock()
+		place := forecast.place
+		forecast.Unlock()
+		if place != "57.4,21.6" {
+			t.Fatalf("weather asked for %v, not at the camera", place)
+		}
+		if w.skipped != "" {
+			expectText(t, got, "event_skipped", "main", w.skipped)
+		} else {
+			expectPhoto(t, got, "X: 200 Y: 5")
+		}
+		at = at.AddDate(0, 0, 1)
+	}
+
+	removeEvent(chat)
+}
+
+func TestAlerts(t *testing.T) {
+	chat := nextID()
+	u := user(chat, "Karl")
+	login(t, chat, u, "secret")
+
+	raiseAlert("test", "alert_sunset", "timeout")
+	expectText(t, fake.wait(t, chat, 1, waitTimeout)[0], "alert_sunset", "timeout")
+
+	// Repeats are not sent again.
+	raiseAlert("test", "alert_sunset", "timeout")
+	fake.quiet(t, chat, 100*time.Millisecond)
+
+	fakeClk.Advance(time.Minute)
+	resolveAlert("test")
+	expectText(t, fake.wait(t, chat, 1, waitTimeout)[0], "alert_resolved", tr("en", "alert_sunset", "timeout"), time.Minute, 2)
+
+	expectWebhook(t, "test", "firing")
+	expectWebhook(t, "test", "resolved")
+}
+
+func TestSessionExpiry(t *testing.T) {
+	chat := nextID()
+	u := user(chat, "Ivan")
+	pass, _ := currentGuestPass()
+	login(t, chat, u, pass)
+
+	fakeClk.Advance(8 * time.Hour)
+	waitExpired(t, chat)
+
+	fake.message(chat, u, "/photo 10 10")
+	expectText(t, fake.wait(t, chat, 1, waitTimeout)[0], "hello", "Ivan")
+}
+
+func TestCameraChoice(t *testing.T) {
+	// A second camera only for this test, the others caption photos without
+	// camera names.
+	cameras["yard"] = &camera{Name: "yard"}
+	cameraNames = append(cameraNames, "yard")
+	t.Cleanup(func() {
+		delete(cameras, "yard")
+		cameraNames = cameraNames[:len(cameraNames)-1]
+	})
+
+	chat := nextID()
+	u := user(chat, "Dace")
+	login(t, chat, u, "secret")
+
+	fake.message(chat, u, "/camera yard")
+	expectText(t, fake.wait(t, chat, 1, waitTimeout)[0], "camera_set", "Dace", "yard")
+
+	// The choice is kept for the chat after its session expires.
+	fakeClk.Advance(8 * time.Hour)
+	waitExpired(t, chat)
+	login(t, chat, u, "secret")
+
+	fake.message(chat, u, "/camera")
+	expectText(t, fake.wait(t, chat, 1, waitTimeout)[0], "camera_current", "Dace", "yard", "main, yard")
+}
+
+// waitExpired blocks until the session of chat is gone.
+func waitExpired(t *testing.T, chat int64) {
+	t.Helper()
+	for deadline := time.Now().Add(waitTimeout); hasSession(chat); time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("session did not expire")
+		}
+	}
+}
+
+func hasSession(chat int64) bool {
+	for _, b := range activeSessions() {
+		if b.chatID == chat {
+			return true
+		}
+	}
+	return false
+}
+
+func TestBreakerTrial(t *testing.T) {
+	br := newBreaker("test")
+	for i := 0; i < br.threshold; i++ {
+		br.failure(fmt.Errorf("no photo"))
+	}
+	if br.available() || br.allow() {
+		t.Fatal("open breaker lets jobs through")
+	}
+	expectWebhook(t, "breaker:test", "firing")
+
+	fakeClk.Advance(br.cooldown)
+	if !br.available() || !br.allow() {
+		t.Fatal("breaker does not let the trial job through after cooldown")
+	}
+	if !br.available() || br.allow() {
+		t.Fatal("half-open breaker lets a second job through during the trial")
+	}
+	br.failure(fmt.Errorf("no photo"))
+	if br.allow() {
+		t.Fatal("failed trial does not open the breaker again")
+	}
+
+	fakeClk.Advance(br.cooldown)
+	br.allow()
+	br.success()
+	if !br.allow() || !br.allow() {
+		t.Fatal("breaker is not closed after a successful trial")
+	}
+	expectWebhook(t, "breaker:test", "resolved")
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"container/heap"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// eventCatchup is how late an event may still take its photo, e.g. when the
+// bot was restarted or the machine was suspended at the scheduled moment.
+var eventCatchup time.Duration
+
+// eventGrace is how late an event may always run, timers wake a little after
+// they are due even when eventCatchup is zero.
+const eventGrace = 30 * time.Second
+
+// nextRun returns when the event takes its next photo after now.
+func (e event) nextRun(now time.Time) time.Time {
+	hour, minute := e.Hour, e.Minute
+	if e.Sunset {
+		hour, minute = sunsetTime()
+	}
+	next := time.Date(now.Year(), now.Month(), now.Day(), hour, minute, 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// entry is an event waiting in the scheduler for its next run.
+type entry struct {
+	chat  int64
+	ev    event
+	at    time.Time
+	index int
+}
+
+// eventHeap orders entries by their next run, the earliest first.
+type eventHeap []*entry
+
+func (h eventHeap) Len() int           { return len(h) }
+func (h eventHeap) Less(i, j int) bool { return h[i].at.Before(h[j].at) }
+
+func (h eventHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *eventHeap) Push(x interface{}) {
+	e := x.(*entry)
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *eventHeap) Pop() interface{} {
+	old := *h
+	e := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return e
+}
+
+// scheduler runs the events of all chats from a single goroutine, sleeping
+// until the earliest of them is due.
+var scheduler = struct {
+	sync.Mutex
+	heap  eventHeap
+	chats map[int64]*entry
+	wake  chan struct{}
+}{chats: make(map[int64]*entry), wake: make(chan struct{}, 1)}
+
+// wakeScheduler makes the scheduler look at the heap again.
+func wakeScheduler() {
+	select {
+	case scheduler.wake <- struct{}{}:
+	default:
+	}
+}
+
+// schedule puts ev of chat to the heap, replacing its previous event.
+// scheduler must be locked.
+func schedule(chat int64, ev event) {
+	at := ev.nextRun(ev.LastRun)
+	if e, ok := scheduler.chats[chat]; ok {
+		e.ev, e.at = ev, at
+		heap.Fix(&scheduler.heap, e.index)
+	} else {
+		e := &entry{chat: chat, ev: ev, at: at}
+		heap.Push(&scheduler.heap, e)
+		scheduler.chats[chat] = e
+	}
+	wakeScheduler()
+}
+
+// chatEvent returns the event of chat.
+func chatEvent(chat int64) (event, bool) {
+	scheduler.Lock()
+	defer scheduler.Unlock()
+
+	e, ok := scheduler.chats[chat]
+	if !ok {
+		return event{}, false
+	}
+	return e.ev, true
+}
+
+// addEvent schedules ev for chat and saves it, so it survives restarts.
+func addEvent(chat int64, ev event) {
+	ev.LastRun = clk.Now()
+
+	scheduler.Lock()
+	schedule(chat, ev)
+	scheduler.Unlock()
+
+	updateStore(func(p *persistent) {
+		p.Events[chat] = ev
+	})
+}
+
+// removeEvent deletes the event of chat and returns it.
+func removeEvent(chat int64) (event, bool) {
+	scheduler.Lock()
+	e, ok := scheduler.chats[chat]
+	if ok {
+		heap.Remove(&scheduler.heap, e.index)
+		delete(scheduler.chats, chat)
+		wakeScheduler()
+	}
+	scheduler.Unlock()
+
+	if !ok {
+		return event{}, false
+	}
+	updateStore(func(p *persistent) {
+		delete(p.Events, chat)
+	})
+	return e.ev, true
+}
+
+// scheduledEvents returns all events ordered by their next run.
+func scheduledEvents() []entry {
+	scheduler.Lock()
+	defer scheduler.Unlock()
+
+	entries := make([]entry, 0, len(scheduler.heap))
+	for _, e := range scheduler.heap {
+		entries = append(entries, *e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].at.Before(entries[j].at) })
+	return entries
+}
+
+// rescheduleSunset moves pending sunset events to the current sunset time.
+// Their next run is computed anew from the last one, the day they were due on
+// may have been picked with a stale sunset time. Due events are left to
+// fireEvents.
+func rescheduleSunset() {
+	now := clk.Now()
+
+	scheduler.Lock()
+	defer scheduler.Unlock()
+
+	for _, e := range scheduler.chats {
+		if !e.ev.Sunset || !e.at.After(now) {
+			continue
+		}
+		e.at = e.ev.nextRun(e.ev.LastRun)
+		if !e.at.After(now) {
+			e.at = e.ev.nextRun(now)
+		}
+		heap.Fix(&scheduler.heap, e.index)
+	}
+	wakeScheduler()
+}
+
+// loadEvents schedules events saved by the last run. Events missed while
+// the bot was down are caught up by the scheduler.
+func loadEvents() {
+	store.Lock()
+	events := make(map[int64]event, len(store.data.Events))
+	for chat, ev := range store.data.Events {
+		events[chat] = ev
+	}
+	store.Unlock()
+
+	scheduler.Lock()
+	defer scheduler.Unlock()
+	for chat, ev := range events {
+		schedule(chat, ev)
+		log.Info().Int64("chat", chat).Str("camera", ev.Camera).Time("next", scheduler.chats[chat].at).Msg("Restored event.")
+	}
+}
+
+// runScheduler takes event photos when they are due.
+func runScheduler() {
+	for {
+		fireEvents()
+
+		var t timer
+		var due <-chan time.Time
+		scheduler.Lock()
+		if len(scheduler.heap) > 0 {
+			t = clk.NewTimer(scheduler.heap[0].at.Sub(clk.Now()))
+			due = t.C()
+		}
+		scheduler.Unlock()
+
+		select {
+		case <-due:
+		case <-scheduler.wake:
+			if t != nil {
+				t.Stop()
+			}
+		}
+	}
+}
+
+// fireEvents starts photos of due events and schedules their next runs.
+// When the bot was down for days only the latest missed run is considered,
+// it is skipped if later than eventCatchup and eventGrace.
+func fireEvents() {
+	now := clk.Now()
+	var handled, fired []entry
+
+	scheduler.Lock()
+	for len(scheduler.heap) > 0 && !scheduler.heap[0].at.After(now) {
+		e := scheduler.heap[0]
+		due := e.at
+		if latest := e.ev.nextRun(now).AddDate(0, 0, -1); latest.After(due) {
+			due = latest
+		}
+		if late := now.Sub(due); late <= eventCatchup+eventGrace {
+			fired = append(fired, *e)
+		} else {
+			log.Warn().Int64("chat", e.chat).Str("camera", e.ev.Camera).Time("due", due).Dur("late", late).Msg("Skipped missed event.")
+		}
+		e.ev.LastRun = now
+		e.at = e.ev.nextRun(now)
+		heap.Fix(&scheduler.heap, 0)
+		handled = append(handled, *e)
+	}
+	scheduler.Unlock()
+
+	if len(handled) == 0 {
+		return
+	}
+	updateStore(func(p *persistent) {
+		for _, e := range handled {
+			if ev, ok := p.Events[e.chat]; ok {
+				ev.LastRun = now
+				p.Events[e.chat] = ev
+			}
+		}
+	})
+
+	for _, e := range fired {
+		go runEvent(e.chat, e.ev)
+	}
+}
+
+// runEvent queues the photo of ev, unless the weather does not meet its
+// conditions. Then the chat is told why the photo was skipped.
+func runEvent(chat int64, ev event) {
+	cam, ok := findCamera(ev.Camera)
+	if !ok {
+		log.Error().Str("camera", ev.Camera).Int64("chat", chat).Msg("Event camera does not exist.")
+		return
+	}
+
+	b := eventSender(chat, ev)
+	if reason, ok := weatherAllows(cam, ev, b.language()); !ok {
+		log.Info().Str("camera", cam.Name).Int64("chat", chat).Str("reason", reason).Msg("Skipped event because of weather.")
+		b.reply("event_skipped", cam.Name, reason)
+		return
+	}
+	if ev.Sunset {
+		log.Info().Str("camera", cam.Name).Ints("cords", []int{ev.X, ev.Y}).Msg("Doing sunset event photo.")
+	} else {
+		log.Info().Str("camera", cam.Name).Ints("cords", []int{ev.X, ev.Y}).Msg("Doing event photo.")
+	}
+	cam.enqueue(b, 0, ev.Owner, ev.X, ev.Y, prioEvent)
+}
+
+// eventSender returns the session of chat or a bot sending to it, when the
+// session has expired.
+func eventSender(chat int64, ev event) *Bot {
+	if b, ok := findSession(chat); ok {
+		return b
+	}
+	return newSender(chat, userLanguage(ev.OwnerID, ""))
+}
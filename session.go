@@ -0,0 +1,84 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/NicoNex/echotron/v3"
+)
+
+// sessions owns the bots of all chats. Updates are dispatched here rather
+// than by echotron.Dispatcher, which reads its sessions without locking and
+// so races with sessions expiring.
+var sessions = struct {
+	sync.Mutex
+	bots map[int64]*Bot
+}{bots: make(map[int64]*Bot)}
+
+// session returns the bot of chatID, creating it for a new chat.
+func session(chatID int64) *Bot {
+	sessions.Lock()
+	defer sessions.Unlock()
+
+	b, ok := sessions.bots[chatID]
+	if !ok {
+		b = newBot(chatID)
+		sessions.bots[chatID] = b
+	}
+	return b
+}
+
+// findSession returns the bot of chatID if the chat has a session.
+func findSession(chatID int64) (*Bot, bool) {
+	sessions.Lock()
+	defer sessions.Unlock()
+
+	b, ok := sessions.bots[chatID]
+	return b, ok
+}
+
+// delSession ends the session of b, the next update of the chat starts a
+// new one.
+func delSession(b *Bot) {
+	sessions.Lock()
+	if sessions.bots[b.chatID] == b {
+		delete(sessions.bots, b.chatID)
+	}
+	sessions.Unlock()
+}
+
+func activeSessions() []*Bot {
+	sessions.Lock()
+	defer sessions.Unlock()
+
+	bots := make([]*Bot, 0, len(sessions.bots))
+	for _, b := range sessions.bots {
+		bots = append(bots, b)
+	}
+	return bots
+}
+
+// poll long polls updates and hands each one to the session of its chat.
+// Like echotron's Dispatcher it drops updates sent while the bot was down.
+func poll(api echotron.API) error {
+	if _, err := api.DeleteWebhook(true); err != nil {
+		return err
+	}
+
+	opts := echotron.UpdateOptions{Timeout: 0}
+	for first := true; ; first = false {
+		res, err := api.GetUpdates(&opts)
+		if err != nil {
+			return err
+		}
+		resolveAlert("poll")
+		if !first {
+			for _, u := range res.Result {
+				go session(u.ChatID()).Update(u)
+			}
+		}
+		if l := len(res.Result); l > 0 {
+			opts.Offset = res.Result[l-1].ID + 1
+		}
+		opts.Timeout = 120
+	}
+}
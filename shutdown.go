@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/NicoNex/echotron/v3"
+	"github.com/rs/zerolog/log"
+)
+
+// stopping is set once shutdown started, no new jobs are accepted then.
+var stopping atomic.Bool
+
+// inflight counts jobs being taken right now.
+var inflight sync.WaitGroup
+
+// captures is the context of photos being taken, shutdown cancels it when
+// they do not finish in time.
+var captures, cancelCaptures = context.WithCancel(context.Background())
+
+// cancelled holds jobs whose capture shutdown cancelled, they are persisted
+// with the queues.
+var cancelled struct {
+	sync.Mutex
+	jobs []pendingJob
+}
+
+// handBack gives job j of cam, cancelled by shutdown, back to be persisted.
+func handBack(cam *camera, j *job) {
+	cancelled.Lock()
+	cancelled.jobs = append(cancelled.jobs, pendingOf(cam, j))
+	cancelled.Unlock()
+}
+
+func pendingOf(cam *camera, j *job) pendingJob {
+	return pendingJob{
+		ID:        j.ID,
+		Camera:    cam.Name,
+		Chat:      j.Chat,
+		User:      j.User,
+		Requester: j.Requester,
+		X:         j.X,
+		Y:         j.Y,
+		Priority:  j.Priority,
+		Clip:      j.Clip,
+	}
+}
+
+// logFile writes logs to the file of the current day. LogsControl replaces
+// the file while other goroutines keep logging.
+type logFile struct {
+	sync.Mutex
+	file *os.File
+}
+
+func (l *logFile) Write(p []byte) (int, error) {
+	l.Lock()
+	defer l.Unlock()
+	if l.file == nil {
+		return os.Stderr.Write(p)
+	}
+	return l.file.Write(p)
+}
+
+// logs is the log output, it is flushed on shutdown.
+var logs logFile
+
+// pendingJob is a queued photo persisted over restart.
+type pendingJob struct {
+	ID        int      `json:"id"`
+	Camera    string   `json:"camera"`
+	Chat      int64    `json:"chat"`
+	User      int64    `json:"user"`
+	Requester string   `json:"requester"`
+	X         int      `json:"x"`
+	Y         int      `json:"y"`
+	Priority  priority `json:"priority"`
+	Clip      int      `json:"clip,omitempty"`
+}
+
+// handleSignals shuts the bot down on SIGINT or SIGTERM.
+func handleSignals() {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGINT, syscall.SIGTERM)
+	sig := <-ch
+	log.Info().Stringer("signal", sig).Msg("Shutting down.")
+	shutdown(envDuration("SHUTDOWN_TIMEOUT", time.Minute))
+	os.Exit(0)
+}
+
+// shutdown stops accepting jobs, waits up to timeout for photos being taken,
+// persists the rest of the queues, notifies their chats and parks cameras.
+// Photos still being taken after timeout are cancelled and persisted too.
+func shutdown(timeout time.Duration) {
+	// Workers and enqueueJob check stopping under smu, so once it is set no
+	// job starts or joins a queue.
+	for _, name := range cameraNames {
+		cameras[name].smu.Lock()
+	}
+	stopping.Store(true)
+	for _, name := range cameraNames {
+		cameras[name].smu.Unlock()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		inflight.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		log.Warn().Dur("timeout", timeout).Msg("Photos still in progress, cancelling them.")
+		cancelCaptures()
+		// Cancelled captures return quickly and hand their jobs back.
+		select {
+		case <-done:
+		case <-time.After(10 * time.Second):
+			log.Error().Msg("Cancelled photos did not stop, they are lost.")
+		}
+	}
+
+	cancelled.Lock()
+	pending := cancelled.jobs
+	cancelled.Unlock()
+	for _, name := range cameraNames {
+		cam := cameras[name]
+		cam.smu.Lock()
+		started := cam.jobs[:0]
+		for _, j := range cam.jobs {
+			if j.Started {
+				started = append(started, j)
+				continue
+			}
+			pending = append(pending, pendingOf(cam, j))
+		}
+		cam.jobs = started
+		cam.smu.Unlock()
+	}
+	updateStore(func(p *persistent) {
+		p.Pending = pending
+	})
+	log.Info().Int("jobs", len(pending)).Msg("Persisted queued photos.")
+
+	chats := make(map[int64]int)
+	for _, pj := range pending {
+		chats[pj.Chat]++
+	}
+	api := echotron.NewAPI(os.Getenv("TOKEN"))
+	for chat, n := range chats {
+		if _, err := api.SendMessage(tr(userLanguage(chat, ""), "shutdown_pending", n), chat, nil); err != nil {
+			log.Error().Err(err).Int64("chat", chat).Msg("Failed to notify chat about shutdown.")
+		}
+	}
+
+	parkX, parkY := envInt("PARK_X", 0), envInt("PARK_Y", 0)
+	for _, name := range cameraNames {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		if err := cameras[name].park(ctx, parkX, parkY); err != nil {
+			log.Error().Err(err).Str("camera", name).Msg("Failed to park camera.")
+		} else {
+			log.Info().Str("camera", name).Ints("cords", []int{parkX, parkY}).Msg("Parked camera.")
+		}
+		cancel()
+	}
+
+	log.Info().Msg("Shutdown complete.")
+	logs.Lock()
+	if logs.file != nil {
+		logs.file.Sync()
+		logs.file.Close()
+		logs.file = nil
+	}
+	logs.Unlock()
+}
+
+// restorePending queues photos persisted by the last shutdown.
+func restorePending() {
+	var pending []pendingJob
+	updateStore(func(p *persistent) {
+		pending, p.Pending = p.Pending, nil
+	})
+
+	for _, pj := range pending {
+		cam, ok := findCamera(pj.Camera)
+		if !ok {
+			log.Warn().Str("camera", pj.Camera).Int("job", pj.ID).Msg("Camera of persisted photo does not exist.")
+			continue
+		}
+		if int64(pj.ID) > atomic.LoadInt64(&jobSeq) {
+			atomic.StoreInt64(&jobSeq, int64(pj.ID))
+		}
+		b := newSender(pj.Chat, userLanguage(pj.User, ""))
+		cam.push(&job{
+			ID:        pj.ID,
+			Requester: pj.Requester,
+			User:      pj.User,
+			Chat:      pj.Chat,
+			X:         pj.X,
+			Y:         pj.Y,
+			Priority:  pj.Priority,
+			Clip:      pj.Clip,
+			Added:     clk.Now(),
+			bot:       b,
+		})
+		log.Info().Str("camera", cam.Name).Int("job", pj.ID).Msg("Restored queued photo.")
+	}
+}
+
+// newSender makes a bot without session, used to deliver restored photos.
+func newSender(chatID int64, lang string) *Bot {
+	return &Bot{
+		chatID: chatID,
+		group:  chatID < 0,
+		lang:   lang,
+		users:  make(map[int64]*member),
+		API:    echotron.NewAPI(os.Getenv("TOKEN")),
+	}
+}
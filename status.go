@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/NicoNex/echotron/v3"
+	"github.com/rs/zerolog/log"
+)
+
+var started time.Time
+
+// captured records a successful capture.
+func (c *camera) captured() {
+	c.smu.Lock()
+	c.lastCapture = clk.Now()
+	c.smu.Unlock()
+}
+
+// failed records the last error of a capture.
+func (c *camera) failed(err error) {
+	c.smu.Lock()
+	c.lastErr = err
+	c.lastErrAt = clk.Now()
+	c.smu.Unlock()
+}
+
+func (b *Bot) statusCameras() string {
+	never := b.tr("status_never")
+	var lines []string
+	for _, name := range cameraNames {
+		cam := cameras[name]
+		pos := cam.position()
+
+		cam.smu.Lock()
+		last, lastErr, lastErrAt := never, never, cam.lastErrAt
+		if !cam.lastCapture.IsZero() {
+			last = cam.lastCapture.Format("02.01 15:04:05")
+		}
+		if cam.lastErr != nil {
+			lastErr = fmt.Sprintf("%v (%v)", cam.lastErr, lastErrAt.Format("02.01 15:04:05"))
+		}
+		cam.smu.Unlock()
+
+		lines = append(lines, b.tr("status_camera", name, pos.X, pos.Y, cam.queueLen(), queue_cap, last, lastErr))
+		for _, j := range cam.queued() {
+			lines = append(lines, b.tr("status_job", j.Requester, j.X, j.Y, clk.Now().Sub(j.Added).Round(time.Second)))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (b *Bot) statusEvents() string {
+	events := scheduledEvents()
+	if len(events) == 0 {
+		return b.tr("status_no_events")
+	}
+
+	var lines []string
+	for _, e := range events {
+		lines = append(lines, b.tr("status_event", e.ev.Owner, e.ev.Camera, e.ev.X, e.ev.Y, e.at.Format("02.01 15:04")))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (b *Bot) cmdStatus(update *echotron.Update, args []string) stateFn {
+	_, expiry := currentGuestPass()
+	hour, minute := sunsetTime()
+	text := b.tr("status",
+		clk.Now().Sub(started).Round(time.Second),
+		len(activeSessions()),
+		expiry.Format("02.01 15:04"),
+		fmt.Sprintf("%02d:%02d", hour, minute),
+		b.statusCameras(),
+		b.statusEvents(),
+	)
+	if _, err := b.SendMessage(text, b.chatID, nil); err != nil {
+		log.Error().Err(err).Msg("Failed to send message.")
+		time.Sleep(10 * time.Second)
+	}
+	return b.handleLogin
+}
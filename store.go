@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+)
+
+type position struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+// persistent is everything the bot keeps between restarts.
+type persistent struct {
+	Positions map[string]position     `json:"positions"`
+	Zones     map[string][]zone       `json:"zones"`
+	Languages map[int64]string        `json:"languages"`
+	Groups    map[int64]groupSettings `json:"groups"`
+	Cameras   map[int64]string        `json:"cameras"`
+	Pending   []pendingJob            `json:"pending"`
+	Events    map[int64]event         `json:"events"`
+	// Sunset is the last fetched sunset time.
+	Sunset *dayTime `json:"sunset,omitempty"`
+}
+
+var store = struct {
+	sync.Mutex
+	path string
+	data persistent
+}{}
+
+// loadStore reads persistent state from STATE_FILE (state.json by default).
+func loadStore() {
+	store.path = os.Getenv("STATE_FILE")
+	if store.path == "" {
+		store.path = "state.json"
+	}
+
+	data, err := os.ReadFile(store.path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Fatal().Err(err).Str("path", store.path).Msg("Failed to read state file.")
+	} else if err == nil {
+		if err := json.Unmarshal(data, &store.data); err != nil {
+			log.Fatal().Err(err).Str("path", store.path).Msg("Failed to parse state file.")
+		}
+	}
+
+	if store.data.Positions == nil {
+		store.data.Positions = make(map[string]position)
+	}
+	if store.data.Zones == nil {
+		store.data.Zones = make(map[string][]zone)
+	}
+	if store.data.Languages == nil {
+		store.data.Languages = make(map[int64]string)
+	}
+	if store.data.Groups == nil {
+		store.data.Groups = make(map[int64]groupSettings)
+	}
+	if store.data.Cameras == nil {
+		store.data.Cameras = make(map[int64]string)
+	}
+	if store.data.Events == nil {
+		store.data.Events = make(map[int64]event)
+	}
+}
+
+// updateStore applies fn to persistent state and writes it to disk.
+func updateStore(fn func(*persistent)) {
+	store.Lock()
+	defer store.Unlock()
+
+	fn(&store.data)
+
+	data, err := json.MarshalIndent(store.data, "", "  ")
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to marshal state.")
+		return
+	}
+	// Write to temporary file first so a crash never leaves half written state.
+	tmp := store.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		log.Error().Err(err).Str("path", tmp).Msg("Failed to write state file.")
+		return
+	}
+	if err := os.Rename(tmp, store.path); err != nil {
+		log.Error().Err(err).Str("path", store.path).Msg("Failed to replace state file.")
+	}
+}
+
+// viewStore calls fn with persistent state locked for reading.
+func viewStore(fn func(*persistent)) {
+	store.Lock()
+	defer store.Unlock()
+	fn(&store.data)
+}
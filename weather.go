@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// weatherNow is the weather at the camera when an event is due.
+type weatherNow struct {
+	// CloudCover is in percent.
+	CloudCover float64
+	// Visibility is in kilometres.
+	Visibility float64
+}
+
+// weatherProvider tells the current weather at a place.
+type weatherProvider interface {
+	current(ctx context.Context, lat, lng float64) (weatherNow, error)
+}
+
+// weather is checked before events with conditions, nil when disabled.
+var weather weatherProvider
+
+// openMeteo queries an Open-Meteo compatible forecast API.
+type openMeteo struct {
+	url string
+}
+
+func (o openMeteo) current(ctx context.Context, lat, lng float64) (weatherNow, error) {
+	q := url.Values{}
+	q.Set("latitude", fmt.Sprint(lat))
+	q.Set("longitude", fmt.Sprint(lng))
+	q.Set("current", "cloud_cover,visibility")
+	req, err := http.NewRequestWithContext(ctx, "GET", o.url+"?"+q.Encode(), nil)
+	if err != nil {
+		return weatherNow{}, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return weatherNow{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return weatherNow{}, fmt.Errorf("weather API returned %v", resp.Status)
+	}
+
+	var data struct {
+		Current struct {
+			CloudCover *float64 `json:"cloud_cover"`
+			// Visibility is in metres.
+			Visibility *float64 `json:"visibility"`
+		} `json:"current"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return weatherNow{}, err
+	}
+	if data.Current.CloudCover == nil || data.Current.Visibility == nil {
+		return weatherNow{}, fmt.Errorf("weather API returned no current weather")
+	}
+	return weatherNow{CloudCover: *data.Current.CloudCover, Visibility: *data.Current.Visibility / 1000}, nil
+}
+
+// loadWeather configures the weather provider from WEATHER_URL, "off"
+// disables weather checks.
+func loadWeather() {
+	u := os.Getenv("WEATHER_URL")
+	switch u {
+	case "off":
+		weather = nil
+	case "":
+		weather = openMeteo{url: "https://api.open-meteo.com/v1/forecast"}
+	default:
+		weather = openMeteo{url: u}
+	}
+}
+
+// condition is a weather requirement of an event, like clouds<80.
+type condition struct {
+	Field string  `json:"field"`
+	Op    string  `json:"op"`
+	Value float64 `json:"value"`
+}
+
+// parseCondition parses clouds<N (percent) or visibility>N (km), either
+// field works with both < and >.
+func parseCondition(s string) (condition, error) {
+	i := strings.IndexAny(s, "<>")
+	if i < 0 {
+		return condition{}, fmt.Errorf("condition %q has no < or >", s)
+	}
+	c := condition{Field: strings.ToLower(s[:i]), Op: s[i : i+1]}
+	if c.Field != "clouds" && c.Field != "visibility" {
+		return condition{}, fmt.Errorf("unknown weather %q", c.Field)
+	}
+	v, err := strconv.ParseFloat(s[i+1:], 64)
+	if err != nil {
+		return condition{}, err
+	}
+	c.Value = v
+	return c, nil
+}
+
+// splitConditions separates weather conditions from other arguments.
+func splitConditions(args []string) ([]string, []string) {
+	var conds, rest []string
+	for _, a := range args {
+		if strings.ContainsAny(a, "<>") {
+			conds = append(conds, a)
+		} else {
+			rest = append(rest, a)
+		}
+	}
+	return conds, rest
+}
+
+// check returns key and arguments of the reason w does not meet c.
+func (c condition) check(w weatherNow) (string, []interface{}, bool) {
+	key, actual := "cond_clouds", w.CloudCover
+	if c.Field == "visibility" {
+		key, actual = "cond_visibility", w.Visibility
+	}
+	ok := actual < c.Value
+	if c.Op == ">" {
+		ok = actual > c.Value
+	}
+	return key, []interface{}{actual, c.Op, c.Value}, ok
+}
+
+// weatherAllows reports whether the weather at cam meets conditions of ev,
+// else it returns the reason translated to lang. Unknown weather never skips
+// a photo.
+func weatherAllows(cam *camera, ev event, lang string) (string, bool) {
+	if len(ev.Conditions) == 0 || weather == nil {
+		return "", true
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	w, err := weather.current(ctx, cam.Lat, cam.Lng)
+	if err != nil {
+		log.Error().Err(err).Str("camera", cam.Name).Msg("Failed to get weather, taking event photo anyway.")
+		raiseAlert("weather", "alert_weather", err)
+		return "", true
+	}
+	resolveAlert("weather")
+
+	var reasons []string
+	for _, c := range ev.Conditions {
+		if key, args, ok := c.check(w); !ok {
+			reasons = append(reasons, tr(lang, key, args...))
+		}
+	}
+	log.Info().Str("camera", cam.Name).Float64("clouds", w.CloudCover).Float64("visibility", w.Visibility).Strs("failed", reasons).Msg("Checked weather for event.")
+	return strings.Join(reasons, ", "), len(reasons) == 0
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/NicoNex/echotron/v3"
+	"github.com/rs/zerolog/log"
+)
+
+// zone is a forbidden rectangle of camera coordinates, bounds are inclusive.
+type zone struct {
+	X1 int `json:"x1"`
+	Y1 int `json:"y1"`
+	X2 int `json:"x2"`
+	Y2 int `json:"y2"`
+}
+
+func newZone(x1, y1, x2, y2 int) zone {
+	if x1 > x2 {
+		x1, x2 = x2, x1
+	}
+	if y1 > y2 {
+		y1, y2 = y2, y1
+	}
+	return zone{X1: x1, Y1: y1, X2: x2, Y2: y2}
+}
+
+func (z zone) contains(x, y int) bool {
+	return x >= z.X1 && x <= z.X2 && y >= z.Y1 && y <= z.Y2
+}
+
+func (z zone) String() string {
+	return fmt.Sprintf("X: %v-%v Y: %v-%v", z.X1, z.X2, z.Y1, z.Y2)
+}
+
+func (c *camera) zones() []zone {
+	var zones []zone
+	viewStore(func(p *persistent) {
+		zones = append(zones, p.Zones[c.Name]...)
+	})
+	return zones
+}
+
+// forbidden reports whether camera must not look at x, y.
+func (c *camera) forbidden(x, y int) bool {
+	for _, z := range c.zones() {
+		if z.contains(x, y) {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *Bot) forbiddenZone(update *echotron.Update, cam *camera, x, y int) {
+	log.Warn().Str("camera", cam.Name).Ints("cords", []int{x, y}).Msg("Coordinates are in no-go zone.")
+	b.reply("zone_forbidden", update.Message.From.FirstName, cam.Name, x, y)
+}
+
+func (b *Bot) cmdZones(update *echotron.Update, args []string) stateFn {
+	cam, _, ok := b.cameraArg(update, args, 0)
+	if !ok {
+		return b.handleLogin
+	}
+
+	var lines []string
+	for i, z := range cam.zones() {
+		lines = append(lines, fmt.Sprintf("%v. %v", i+1, z))
+	}
+	if len(lines) == 0 {
+		b.reply("zones_none", update.Message.From.FirstName, cam.Name)
+	} else {
+		b.reply("zones_list", update.Message.From.FirstName, cam.Name, strings.Join(lines, "\n"))
+	}
+	return b.handleLogin
+}
+
+func (b *Bot) cmdZoneAdd(update *echotron.Update, args []string) stateFn {
+	cam, cords, ok := b.cameraArg(update, args, 4)
+	if !ok {
+		return b.handleLogin
+	}
+
+	// The argument schema makes cords four numbers.
+	var n [4]int
+	for i := range n {
+		n[i], _ = strconv.Atoi(cords[i])
+	}
+
+	z := newZone(n[0], n[1], n[2], n[3])
+	updateStore(func(p *persistent) {
+		p.Zones[cam.Name] = append(p.Zones[cam.Name], z)
+	})
+	log.Info().Strs("user", []string{update.Message.From.FirstName, update.Message.From.LastName, update.Message.From.Username}).Str("camera", cam.Name).Stringer("zone", z).Msg("Added no-go zone.")
+	b.reply("zone_added", update.Message.From.FirstName, z, cam.Name)
+	return b.handleLogin
+}
+
+func (b *Bot) cmdZoneDel(update *echotron.Update, args []string) stateFn {
+	cam, nums, ok := b.cameraArg(update, args, 1)
+	if !ok {
+		return b.handleLogin
+	}
+
+	// The argument schema makes nums a single number.
+	i, _ := strconv.Atoi(nums[0])
+	deleted := false
+	updateStore(func(p *persistent) {
+		zones := p.Zones[cam.Name]
+		if i < 1 || i > len(zones) {
+			return
+		}
+		p.Zones[cam.Name] = append(zones[:i-1:i-1], zones[i:]...)
+		deleted = true
+	})
+	if !deleted {
+		log.Warn().Strs("args", args).Msg("Invalid zone number.")
+		b.reply("zonedel_usage", update.Message.From.FirstName)
+		return b.handleLogin
+	}
+
+	log.Info().Strs("user", []string{update.Message.From.FirstName, update.Message.From.LastName, update.Message.From.Username}).Str("camera", cam.Name).Int("zone", i).Msg("Deleted no-go zone.")
+	b.reply("zone_deleted", update.Message.From.FirstName, i, cam.Name)
+	return b.handleLogin
+}